@@ -2,9 +2,13 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"strconv"
@@ -85,7 +89,7 @@ func main() {
 			rows, err := pgDB.GetDB().Query(`
 				UPDATE jobs 
 				SET status = 'completed', completed_at = NOW()
-				WHERE (completed_tasks + failed_tasks) >= total_tasks 
+				WHERE (completed_tasks + failed_tasks + skipped_tasks) >= total_tasks 
 				  AND status = 'running'
 				RETURNING id
 			`)
@@ -181,6 +185,24 @@ func main() {
 			findLinks = v
 		}
 
+		// Stop following links this many links away from the seed URLs
+		maxDepth := 0
+		if depthStr := r.URL.Query().Get("max_depth"); depthStr != "" {
+			v, err := strconv.Atoi(depthStr)
+			if err != nil || v < 0 {
+				http.Error(w, "Invalid max_depth parameter", http.StatusBadRequest)
+				return
+			}
+			maxDepth = v
+		}
+
+		// Include/exclude rules applied to URLs from every source
+		includePaths, excludePaths, rulePrecedence, ok := parseRules(r.URL.Query())
+		if !ok {
+			http.Error(w, "Invalid include, exclude or rule_precedence parameter", http.StatusBadRequest)
+			return
+		}
+
 		// Override sitemap default flag
 		useSitemap := true
 		if sitemapStr := r.URL.Query().Get("sitemap"); sitemapStr != "" {
@@ -203,12 +225,246 @@ func main() {
 			jobConcurrency = v
 		}
 
+		// Re-request pages until the edge confirms a cache HIT
+		verifyCache := false
+		if verifyStr := r.URL.Query().Get("verify"); verifyStr != "" {
+			v, err := strconv.ParseBool(verifyStr)
+			if err != nil {
+				http.Error(w, "Invalid verify parameter", http.StatusBadRequest)
+				return
+			}
+			verifyCache = v
+		}
+
+		verifyAttempts := 0
+		if attemptsStr := r.URL.Query().Get("verify_attempts"); attemptsStr != "" {
+			v, err := strconv.Atoi(attemptsStr)
+			if err != nil || v < 0 {
+				http.Error(w, "Invalid verify_attempts parameter", http.StatusBadRequest)
+				return
+			}
+			verifyAttempts = v
+		}
+
+		verifyDelayMs := 0
+		if delayStr := r.URL.Query().Get("verify_delay_ms"); delayStr != "" {
+			v, err := strconv.Atoi(delayStr)
+			if err != nil || v < 0 {
+				http.Error(w, "Invalid verify_delay_ms parameter", http.StatusBadRequest)
+				return
+			}
+			verifyDelayMs = v
+		}
+
+		// Cap the bytes read per page so large assets can't exhaust workers
+		var maxBodySize int64
+		if sizeStr := r.URL.Query().Get("max_body_size"); sizeStr != "" {
+			v, err := strconv.ParseInt(sizeStr, 10, 64)
+			if err != nil || v < -1 {
+				http.Error(w, "Invalid max_body_size parameter", http.StatusBadRequest)
+				return
+			}
+			maxBodySize = v
+		}
+
+		// Cap on page URLs read from the site's sitemaps
+		maxSitemapURLs := 0
+		if urlsStr := r.URL.Query().Get("max_sitemap_urls"); urlsStr != "" {
+			v, err := strconv.Atoi(urlsStr)
+			if err != nil || v < 0 {
+				http.Error(w, "Invalid max_sitemap_urls parameter", http.StatusBadRequest)
+				return
+			}
+			maxSitemapURLs = v
+		}
+
+		// Warm each page once per named request variant, e.g. variants=desktop,mobile,br
+		var variants []jobs.RequestVariant
+		if variantsStr := r.URL.Query().Get("variants"); variantsStr != "" {
+			v, err := jobs.ParseVariants(variantsStr)
+			if err != nil {
+				http.Error(w, "Invalid variants parameter", http.StatusBadRequest)
+				return
+			}
+			variants = v
+		}
+
+		// Also warm each page at these edge IPs, and at the edges these DNS servers return
+		var edges, dnsServers []string
+		if edgesStr := r.URL.Query().Get("edges"); edgesStr != "" {
+			for _, edge := range strings.Split(edgesStr, ",") {
+				edge = strings.TrimSpace(edge)
+				if err := crawler.ValidateEdge(edge); err != nil {
+					http.Error(w, "Invalid edges parameter", http.StatusBadRequest)
+					return
+				}
+				edges = append(edges, edge)
+			}
+		}
+		if serversStr := r.URL.Query().Get("dns_servers"); serversStr != "" {
+			for _, server := range strings.Split(serversStr, ",") {
+				// DNS servers are IP addresses, optionally with a port, as edges are
+				server = strings.TrimSpace(server)
+				if err := crawler.ValidateEdge(server); err != nil {
+					http.Error(w, "Invalid dns_servers parameter", http.StatusBadRequest)
+					return
+				}
+				dnsServers = append(dnsServers, server)
+			}
+		}
+
+		// Sitemap extensions to warm alongside the listed pages
+		includeHreflang, includeImages, includeVideos := false, false, false
+		for param, include := range map[string]*bool{"hreflang": &includeHreflang, "images": &includeImages, "videos": &includeVideos} {
+			if includeStr := r.URL.Query().Get(param); includeStr != "" {
+				v, err := strconv.ParseBool(includeStr)
+				if err != nil {
+					http.Error(w, "Invalid "+param+" parameter", http.StatusBadRequest)
+					return
+				}
+				*include = v
+			}
+		}
+
+		// Skip paths robots.txt disallows and space requests by its Crawl-delay
+		respectRobots := false
+		if robotsStr := r.URL.Query().Get("respect_robots"); robotsStr != "" {
+			v, err := strconv.ParseBool(robotsStr)
+			if err != nil {
+				http.Error(w, "Invalid respect_robots parameter", http.StatusBadRequest)
+				return
+			}
+			respectRobots = v
+		}
+
+		// Only warm sitemap URLs the domain's previous job didn't find
+		onlyAddedURLs := false
+		if onlyAddedStr := r.URL.Query().Get("only_added_urls"); onlyAddedStr != "" {
+			v, err := strconv.ParseBool(onlyAddedStr)
+			if err != nil {
+				http.Error(w, "Invalid only_added_urls parameter", http.StatusBadRequest)
+				return
+			}
+			onlyAddedURLs = v
+		}
+
+		// Leave out nofollow links, and warm the canonical URLs pages declare
+		respectNofollow := false
+		if nofollowStr := r.URL.Query().Get("respect_nofollow"); nofollowStr != "" {
+			v, err := strconv.ParseBool(nofollowStr)
+			if err != nil {
+				http.Error(w, "Invalid respect_nofollow parameter", http.StatusBadRequest)
+				return
+			}
+			respectNofollow = v
+		}
+		enqueueCanonical := false
+		if canonicalStr := r.URL.Query().Get("enqueue_canonical"); canonicalStr != "" {
+			v, err := strconv.ParseBool(canonicalStr)
+			if err != nil {
+				http.Error(w, "Invalid enqueue_canonical parameter", http.StatusBadRequest)
+				return
+			}
+			enqueueCanonical = v
+		}
+
+		// Warm the assets pages reference, including those on the given CDN hosts
+		findAssets := false
+		if assetsStr := r.URL.Query().Get("find_assets"); assetsStr != "" {
+			v, err := strconv.ParseBool(assetsStr)
+			if err != nil {
+				http.Error(w, "Invalid find_assets parameter", http.StatusBadRequest)
+				return
+			}
+			findAssets = v
+		}
+		assetHosts := splitList(strings.ToLower(r.URL.Query().Get("asset_hosts")))
+		for _, host := range assetHosts {
+			if strings.ContainsAny(host, "/: ") {
+				http.Error(w, "Invalid asset_hosts parameter", http.StatusBadRequest)
+				return
+			}
+		}
+
+		// How URLs are canonicalised before they become pages
+		urlPolicy, ok := parseURLPolicy(r.URL.Query())
+		if !ok {
+			http.Error(w, "Invalid trailing_slash parameter", http.StatusBadRequest)
+			return
+		}
+
+		// Sitemaps to read instead of discovering the site's sitemaps, and feeds or
+		// text sitemaps to read alongside them
+		sitemapURLs, ok := parseURLList(r.URL.Query().Get("sitemap_urls"))
+		if !ok {
+			http.Error(w, "Invalid sitemap_urls parameter", http.StatusBadRequest)
+			return
+		}
+		feedURLs, ok := parseURLList(r.URL.Query().Get("feed_urls"))
+		if !ok {
+			http.Error(w, "Invalid feed_urls parameter", http.StatusBadRequest)
+			return
+		}
+
+		// Only warm sitemap pages modified since a time, or since the last completed job
+		var modifiedSince *time.Time
+		modifiedSinceLastJob := false
+		if sinceStr := r.URL.Query().Get("modified_since"); sinceStr != "" {
+			if sinceStr == "last_job" {
+				modifiedSinceLastJob = true
+			} else {
+				since, err := time.Parse(time.RFC3339, sinceStr)
+				if err != nil {
+					since, err = time.Parse("2006-01-02", sinceStr)
+				}
+				if err != nil {
+					http.Error(w, "Invalid modified_since parameter", http.StatusBadRequest)
+					return
+				}
+				modifiedSince = &since
+			}
+		}
+
+		// Credentials for protected sites, sent in a POST body
+		auth, err := parseAuth(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		opts := &jobs.JobOptions{
-			Domain:      domain,
-			UseSitemap:  useSitemap,
-			Concurrency: jobConcurrency,
-			FindLinks:   findLinks,
-			MaxPages:    maxPages,
+			Domain:               domain,
+			UseSitemap:           useSitemap,
+			Concurrency:          jobConcurrency,
+			FindLinks:            findLinks,
+			MaxPages:             maxPages,
+			VerifyCache:          verifyCache,
+			VerifyAttempts:       verifyAttempts,
+			VerifyDelayMs:        verifyDelayMs,
+			MaxBodySize:          maxBodySize,
+			Variants:             variants,
+			Edges:                edges,
+			DNSServers:           dnsServers,
+			Auth:                 auth,
+			ModifiedSince:        modifiedSince,
+			ModifiedSinceLastJob: modifiedSinceLastJob,
+			MaxSitemapURLs:       maxSitemapURLs,
+			FeedURLs:             feedURLs,
+			IncludeHreflang:      includeHreflang,
+			IncludeImages:        includeImages,
+			IncludeVideos:        includeVideos,
+			RespectRobots:        respectRobots,
+			SitemapURLs:          sitemapURLs,
+			OnlyAddedURLs:        onlyAddedURLs,
+			FindAssets:           findAssets,
+			AssetHosts:           assetHosts,
+			URLPolicy:            urlPolicy,
+			RespectNofollow:      respectNofollow,
+			EnqueueCanonical:     enqueueCanonical,
+			MaxDepth:             maxDepth,
+			IncludePaths:         includePaths,
+			ExcludePaths:         excludePaths,
+			RulePrecedence:       rulePrecedence,
 		}
 		job, err := jobsManager.CreateJob(r.Context(), opts)
 		if err != nil {
@@ -226,9 +482,92 @@ func main() {
 			"concurrency": strconv.Itoa(jobConcurrency),
 			"find_links":  strconv.FormatBool(findLinks),
 			"max_pages":   strconv.Itoa(maxPages),
+			"verify":      strconv.FormatBool(verifyCache),
 		})
 	})
 
+	// Preview what a job would warm without creating it
+	http.HandleFunc("/preview", func(w http.ResponseWriter, r *http.Request) {
+		domain := r.URL.Query().Get("domain")
+		if domain == "" {
+			http.Error(w, "Domain parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		concurrency := 5
+		if concurrencyStr := r.URL.Query().Get("concurrency"); concurrencyStr != "" {
+			v, err := strconv.Atoi(concurrencyStr)
+			if err != nil || v < 1 {
+				http.Error(w, "Invalid concurrency parameter", http.StatusBadRequest)
+				return
+			}
+			concurrency = v
+		}
+
+		sampleSize := jobs.DefaultPreviewSampleSize
+		if sampleStr := r.URL.Query().Get("sample"); sampleStr != "" {
+			v, err := strconv.Atoi(sampleStr)
+			if err != nil || v < 1 || v > 1000 {
+				http.Error(w, "Invalid sample parameter", http.StatusBadRequest)
+				return
+			}
+			sampleSize = v
+		}
+
+		maxSitemapURLs := 0
+		if maxStr := r.URL.Query().Get("max_sitemap_urls"); maxStr != "" {
+			v, err := strconv.Atoi(maxStr)
+			if err != nil || v < 1 {
+				http.Error(w, "Invalid max_sitemap_urls parameter", http.StatusBadRequest)
+				return
+			}
+			maxSitemapURLs = v
+		}
+
+		sitemapURLs, ok := parseURLList(r.URL.Query().Get("sitemap_urls"))
+		if !ok {
+			http.Error(w, "Invalid sitemap_urls parameter", http.StatusBadRequest)
+			return
+		}
+		feedURLs, ok := parseURLList(r.URL.Query().Get("feed_urls"))
+		if !ok {
+			http.Error(w, "Invalid feed_urls parameter", http.StatusBadRequest)
+			return
+		}
+		urlPolicy, ok := parseURLPolicy(r.URL.Query())
+		if !ok {
+			http.Error(w, "Invalid trailing_slash parameter", http.StatusBadRequest)
+			return
+		}
+		includePaths, excludePaths, rulePrecedence, ok := parseRules(r.URL.Query())
+		if !ok {
+			http.Error(w, "Invalid include, exclude or rule_precedence parameter", http.StatusBadRequest)
+			return
+		}
+
+		opts := &jobs.JobOptions{
+			Domain:         domain,
+			UseSitemap:     true,
+			Concurrency:    concurrency,
+			IncludePaths:   includePaths,
+			ExcludePaths:   excludePaths,
+			RulePrecedence: rulePrecedence,
+			MaxSitemapURLs: maxSitemapURLs,
+			SitemapURLs:    sitemapURLs,
+			FeedURLs:       feedURLs,
+			URLPolicy:      urlPolicy,
+		}
+		preview, err := jobsManager.PreviewJob(r.Context(), opts, sampleSize)
+		if err != nil {
+			log.Error().Err(err).Str("domain", domain).Msg("Failed to preview job")
+			http.Error(w, "Failed to preview job", http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(preview)
+	})
+
 	http.HandleFunc("/job-status", func(w http.ResponseWriter, r *http.Request) {
 		jobID := r.URL.Query().Get("job_id")
 		if jobID == "" {
@@ -236,29 +575,159 @@ func main() {
 			return
 		}
 
-		var total, completed, failed int
+		var total, completed, failed, skipped, warmed int
+		var totalBytes int64
 		var status string
 		err := pgDB.GetDB().QueryRowContext(r.Context(), `
-			SELECT total_tasks, completed_tasks, failed_tasks, status 
+			SELECT total_tasks, completed_tasks, failed_tasks, skipped_tasks, warmed_tasks, total_bytes, status 
 			FROM jobs WHERE id = $1
-		`, jobID).Scan(&total, &completed, &failed, &status)
+		`, jobID).Scan(&total, &completed, &failed, &skipped, &warmed, &totalBytes, &status)
 
 		if err != nil {
 			http.Error(w, "Job not found", http.StatusNotFound)
 			return
 		}
 
+		// Count completed tasks by warning category
+		warnings := make(map[string]int)
+		rows, err := pgDB.GetDB().QueryContext(r.Context(), `
+			SELECT warning_type, COUNT(*)
+			FROM tasks
+			WHERE job_id = $1 AND warning_type IS NOT NULL
+			GROUP BY warning_type
+		`, jobID)
+		if err != nil {
+			http.Error(w, "Failed to get warning counts", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var warningType string
+			var count int
+			if err := rows.Scan(&warningType, &count); err != nil {
+				http.Error(w, "Failed to get warning counts", http.StatusInternalServerError)
+				return
+			}
+			warnings[warningType] = count
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Failed to get warning counts", http.StatusInternalServerError)
+			return
+		}
+
+		// Completed tasks whose other variants or edges failed
+		var variantFailed int
+		err = pgDB.GetDB().QueryRowContext(r.Context(), `
+			SELECT COUNT(*) FROM tasks WHERE job_id = $1 AND variant_failures IS NOT NULL
+		`, jobID).Scan(&variantFailed)
+		if err != nil {
+			http.Error(w, "Failed to get variant failure count", http.StatusInternalServerError)
+			return
+		}
+
+		// Average the timing breakdown of completed tasks. Connection setup phases
+		// only count requests that opened a new connection.
+		var avgDNS, avgConnect, avgTLS, avgTTFB, p95TTFB, avgDownload float64
+		var newConnections int
+		err = pgDB.GetDB().QueryRowContext(r.Context(), `
+			SELECT
+				COALESCE(AVG(dns_lookup_time) FILTER (WHERE NOT conn_reused), 0),
+				COALESCE(AVG(tcp_connect_time) FILTER (WHERE NOT conn_reused), 0),
+				COALESCE(AVG(tls_handshake_time) FILTER (WHERE NOT conn_reused), 0),
+				COALESCE(AVG(ttfb), 0),
+				COALESCE(PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY ttfb), 0),
+				COALESCE(AVG(download_time), 0),
+				COUNT(*) FILTER (WHERE NOT conn_reused)
+			FROM tasks
+			WHERE job_id = $1 AND status = 'completed' AND ttfb IS NOT NULL
+		`, jobID).Scan(&avgDNS, &avgConnect, &avgTLS, &avgTTFB, &p95TTFB, &avgDownload, &newConnections)
+		if err != nil {
+			http.Error(w, "Failed to get timing breakdown", http.StatusInternalServerError)
+			return
+		}
+
+		// Hit ratio for each request variant and pinned edge the job warmed
+		variantStats, err := taskResultStats(r.Context(), pgDB.GetDB(), jobID, "variant")
+		if err != nil {
+			http.Error(w, "Failed to get variant results", http.StatusInternalServerError)
+			return
+		}
+		edgeStats, err := taskResultStats(r.Context(), pgDB.GetDB(), jobID, "edge")
+		if err != nil {
+			http.Error(w, "Failed to get edge results", http.StatusInternalServerError)
+			return
+		}
+
+		sitemaps, err := jobSitemapResults(r.Context(), pgDB.GetDB(), jobID)
+		if err != nil {
+			http.Error(w, "Failed to get sitemap results", http.StatusInternalServerError)
+			return
+		}
+
+		depths, err := jobDepthCounts(r.Context(), pgDB.GetDB(), jobID)
+		if err != nil {
+			http.Error(w, "Failed to get depth counts", http.StatusInternalServerError)
+			return
+		}
+
+		ruleExclusions, err := jobRuleExclusions(r.Context(), pgDB.GetDB(), jobID)
+		if err != nil {
+			http.Error(w, "Failed to get rule exclusions", http.StatusInternalServerError)
+			return
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"job_id":    jobID,
-			"status":    status,
-			"total":     total,
-			"completed": completed,
-			"failed":    failed,
-			"progress":  float64(completed+failed) / float64(total) * 100,
+			"job_id":          jobID,
+			"status":          status,
+			"total":           total,
+			"completed":       completed,
+			"failed":          failed,
+			"skipped":         skipped,
+			"warmed":          warmed,
+			"warnings":        warnings,
+			"variant_failed":  variantFailed,
+			"bytes":           totalBytes,
+			"variants":        variantStats,
+			"edges":           edgeStats,
+			"sitemaps":        sitemaps,
+			"depths":          depths,
+			"rule_exclusions": ruleExclusions,
+			"timings": map[string]interface{}{
+				"avg_dns_lookup_ms":    avgDNS,
+				"avg_tcp_connect_ms":   avgConnect,
+				"avg_tls_handshake_ms": avgTLS,
+				"avg_ttfb_ms":          avgTTFB,
+				"p95_ttfb_ms":          p95TTFB,
+				"avg_download_ms":      avgDownload,
+				"new_connections":      newConnections,
+			},
+			"progress":  float64(completed+failed+skipped) / float64(total) * 100,
 		})
 	})
 
+	// Paths a job's sources found that another job didn't, and the reverse
+	http.HandleFunc("/job-diff", func(w http.ResponseWriter, r *http.Request) {
+		jobID := r.URL.Query().Get("job_id")
+		if jobID == "" {
+			http.Error(w, "job_id parameter required", http.StatusBadRequest)
+			return
+		}
+
+		diff, err := jobsManager.DiffJobs(r.Context(), jobID, r.URL.Query().Get("previous_job_id"), r.URL.Query().Get("source"))
+		if errors.Is(err, jobs.ErrNoPreviousJob) {
+			http.Error(w, "Job has no previous job to compare with", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Error().Err(err).Str("job_id", jobID).Msg("Failed to diff jobs")
+			http.Error(w, "Failed to diff jobs", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(diff)
+	})
+
 	// Create a new HTTP server
 	server := &http.Server{
 		Addr: ":" + config.Port,
@@ -306,6 +775,242 @@ func main() {
 	log.Info().Msg("Server stopped")
 }
 
+// taskResultStats returns hit counts and ratios from task_results grouped by the given
+// column ("variant" or "edge"). Edge results include the normally resolved request as "default".
+func taskResultStats(ctx context.Context, sqlDB *sql.DB, jobID, column string) (map[string]interface{}, error) {
+	if column != "variant" && column != "edge" {
+		return nil, fmt.Errorf("unsupported task result grouping: %s", column)
+	}
+
+	rows, err := sqlDB.QueryContext(ctx, fmt.Sprintf(`
+		SELECT COALESCE(NULLIF(%[1]s, ''), 'default'),
+			COUNT(*),
+			COUNT(*) FILTER (WHERE cache_status = 'HIT'),
+			COUNT(*) FILTER (WHERE error IS NOT NULL)
+		FROM task_results
+		WHERE job_id = $1
+		GROUP BY %[1]s
+	`, column), jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make(map[string]interface{})
+	for rows.Next() {
+		var key string
+		var total, hits, errorCount int
+		if err := rows.Scan(&key, &total, &hits, &errorCount); err != nil {
+			return nil, err
+		}
+		stats[key] = map[string]interface{}{
+			"total":     total,
+			"hits":      hits,
+			"errors":    errorCount,
+			"hit_ratio": float64(hits) / float64(total),
+		}
+	}
+	return stats, rows.Err()
+}
+
+// splitList splits a comma-separated parameter, dropping empty items
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseURLList parses a comma-separated list of absolute http(s) URLs
+func parseURLList(list string) ([]string, bool) {
+	var urls []string
+	for _, raw := range strings.Split(list, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, false
+		}
+		urls = append(urls, raw)
+	}
+	return urls, true
+}
+
+// jobDepthCounts returns the number of a job's tasks at each link depth, by status
+func jobDepthCounts(ctx context.Context, sqlDB *sql.DB, jobID string) (map[int]map[string]int, error) {
+	rows, err := sqlDB.QueryContext(ctx, `
+		SELECT depth, status, COUNT(*)
+		FROM tasks
+		WHERE job_id = $1
+		GROUP BY depth, status
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	depths := make(map[int]map[string]int)
+	for rows.Next() {
+		var depth, count int
+		var status string
+		if err := rows.Scan(&depth, &status, &count); err != nil {
+			return nil, err
+		}
+		if depths[depth] == nil {
+			depths[depth] = make(map[string]int)
+		}
+		depths[depth][status] = count
+		depths[depth]["total"] += count
+	}
+	return depths, rows.Err()
+}
+
+// jobRuleExclusions returns the number of URLs each of a job's include/exclude rules dropped
+func jobRuleExclusions(ctx context.Context, sqlDB *sql.DB, jobID string) (map[string]int, error) {
+	rows, err := sqlDB.QueryContext(ctx, `
+		SELECT rule, COUNT(*)
+		FROM job_excluded_urls
+		WHERE job_id = $1
+		GROUP BY rule
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exclusions := make(map[string]int)
+	for rows.Next() {
+		var rule string
+		var count int
+		if err := rows.Scan(&rule, &count); err != nil {
+			return nil, err
+		}
+		exclusions[rule] = count
+	}
+	return exclusions, rows.Err()
+}
+
+// parseRules reads the include and exclude parameters, which may be repeated,
+// and rule_precedence. Each parameter is a comma-separated list of patterns,
+// except that a re: pattern takes the rest of its parameter, as regular
+// expressions may contain commas.
+func parseRules(query url.Values) ([]string, []string, string, bool) {
+	patterns := func(values []string) []string {
+		var list []string
+		for _, value := range values {
+			for value != "" {
+				item, rest, _ := strings.Cut(value, ",")
+				if trimmed := strings.TrimSpace(value); strings.HasPrefix(trimmed, "re:") {
+					item, rest = trimmed, ""
+				}
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+				value = rest
+			}
+		}
+		return list
+	}
+	include, exclude := patterns(query["include"]), patterns(query["exclude"])
+	precedence := strings.ToLower(query.Get("rule_precedence"))
+	if _, err := crawler.CompileURLRules(include, exclude, precedence); err != nil {
+		return nil, nil, "", false
+	}
+	return include, exclude, precedence, true
+}
+
+// authParams are the parameters that carry credentials for protected sites
+var authParams = []string{"auth_user", "auth_pass", "header", "cookie"}
+
+// parseAuth reads credentials for a protected site from a POST form body. They're
+// rejected in the query string, which ends up in access logs, proxy logs, browser
+// history and Referer headers. Headers and cookies can be repeated. It returns
+// nil when no credentials were sent.
+func parseAuth(r *http.Request) (*crawler.RequestAuth, error) {
+	query := r.URL.Query()
+	for _, param := range authParams {
+		if query.Has(param) {
+			return nil, fmt.Errorf("The %s parameter must be sent in a POST body, not the query string", param)
+		}
+	}
+	if err := r.ParseForm(); err != nil {
+		return nil, errors.New("Invalid request body")
+	}
+
+	auth := &crawler.RequestAuth{
+		Username: r.PostForm.Get("auth_user"),
+		Password: r.PostForm.Get("auth_pass"),
+	}
+	for _, header := range r.PostForm["header"] {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, errors.New("Invalid header parameter")
+		}
+		if auth.Headers == nil {
+			auth.Headers = make(map[string]string)
+		}
+		auth.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	for _, cookie := range r.PostForm["cookie"] {
+		name, value, ok := strings.Cut(cookie, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, errors.New("Invalid cookie parameter")
+		}
+		if auth.Cookies == nil {
+			auth.Cookies = make(map[string]string)
+		}
+		auth.Cookies[strings.TrimSpace(name)] = value
+	}
+	if auth.IsEmpty() {
+		return nil, nil
+	}
+	return auth, nil
+}
+
+// parseURLPolicy reads the trailing_slash and tracking_params parameters.
+// tracking_params replaces the default tracking parameters, and "none" keeps them all.
+func parseURLPolicy(query url.Values) (crawler.URLPolicy, bool) {
+	policy := crawler.URLPolicy{TrailingSlash: strings.ToLower(query.Get("trailing_slash"))}
+	if !crawler.ValidTrailingSlash(policy.TrailingSlash) {
+		return policy, false
+	}
+	if params := query.Get("tracking_params"); params == "none" {
+		policy.TrackingParams = []string{}
+	} else {
+		policy.TrackingParams = splitList(params)
+	}
+	return policy, true
+}
+
+// jobSitemapResults returns the outcome of each sitemap read for a job
+func jobSitemapResults(ctx context.Context, sqlDB *sql.DB, jobID string) ([]crawler.SitemapResult, error) {
+	rows, err := sqlDB.QueryContext(ctx, `
+		SELECT url, COALESCE(parent_url, ''), depth, COALESCE(status_code, 0), COALESCE(format, ''),
+			url_count, child_count, COALESCE(error, '')
+		FROM job_sitemaps
+		WHERE job_id = $1
+		ORDER BY id
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []crawler.SitemapResult{}
+	for rows.Next() {
+		var r crawler.SitemapResult
+		if err := rows.Scan(&r.URL, &r.ParentURL, &r.Depth, &r.StatusCode, &r.Format, &r.URLCount, &r.ChildCount, &r.Error); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
 // getEnvWithDefault retrieves an environment variable or returns a default value if not set
 func getEnvWithDefault(key, defaultValue string) string {
 	value := os.Getenv(key)
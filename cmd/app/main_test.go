@@ -98,3 +98,41 @@ func TestRateLimiter(t *testing.T) {
 		t.Errorf("Request from different IP should be allowed")
 	}
 }
+
+func TestParseAuth(t *testing.T) {
+	// Credentials in the query string are rejected
+	for _, query := range []string{"auth_user=a", "auth_pass=b", "header=X-Token:%20c", "cookie=session=d"} {
+		req := httptest.NewRequest("POST", "/site?domain=example.com&"+query, nil)
+		if _, err := parseAuth(req); err == nil {
+			t.Errorf("Expected credentials in the query string %q to be rejected", query)
+		}
+	}
+
+	body := "auth_user=preview&auth_pass=secret&header=X-Token:%20abc&cookie=session=xyz&cookie=theme=dark"
+	req := httptest.NewRequest("POST", "/site?domain=example.com", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	auth, err := parseAuth(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if auth.Username != "preview" || auth.Password != "secret" {
+		t.Errorf("Expected basic auth preview:secret, got %s:%s", auth.Username, auth.Password)
+	}
+	if auth.Headers["X-Token"] != "abc" {
+		t.Errorf("Expected X-Token header abc, got %q", auth.Headers["X-Token"])
+	}
+	if auth.Cookies["session"] != "xyz" || auth.Cookies["theme"] != "dark" {
+		t.Errorf("Expected both cookies, got %v", auth.Cookies)
+	}
+
+	req = httptest.NewRequest("GET", "/site?domain=example.com", nil)
+	if auth, err := parseAuth(req); err != nil || auth != nil {
+		t.Errorf("Expected no auth without credentials, got %v, %v", auth, err)
+	}
+
+	req = httptest.NewRequest("POST", "/site", strings.NewReader("header=missing-colon"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if _, err := parseAuth(req); err == nil {
+		t.Error("Expected an invalid header to be rejected")
+	}
+}
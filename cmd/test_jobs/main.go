@@ -60,14 +60,16 @@ func main() {
 
 	// Create worker pool
 	var jobWorkers int = 3
-	workerPool := jobs.NewWorkerPool(database.GetDB(), crawler, jobWorkers, database.GetConfig())
+	dbQueue := db.NewDbQueue(database.GetDB())
+	workerPool := jobs.NewWorkerPool(database.GetDB(), dbQueue, crawler, jobWorkers, database.GetConfig())
 	workerPool.Start(context.Background())
 	defer workerPool.Stop()
 
 	log.Info().Msg("Worker pool started with " + strconv.Itoa(jobWorkers) + " workers")
 
 	// Create a test job
-	jobManager := jobs.NewJobManager(database.GetDB(), crawler, nil)
+	jobManager := jobs.NewJobManager(database.GetDB(), dbQueue, crawler, workerPool)
+	workerPool.SetJobManager(jobManager)
 
 	// Set up job options
 	jobOptions := &jobs.JobOptions{
@@ -0,0 +1,199 @@
+package crawler
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/html"
+)
+
+// cssURLPattern matches url(...) references and @import strings in CSS
+var cssURLPattern = regexp.MustCompile(`url\(\s*(?:'([^']*)'|"([^"]*)"|([^)'"\s]*))\s*\)|@import\s+(?:'([^']*)'|"([^"]*)")`)
+
+// assetLinkRels are the link rel values whose href is an asset worth warming
+var assetLinkRels = map[string]bool{
+	"stylesheet":       true,
+	"preload":          true,
+	"modulepreload":    true,
+	"icon":             true,
+	"apple-touch-icon": true,
+}
+
+// assetCollector resolves and deduplicates the asset URLs found in a document
+type assetCollector struct {
+	base   *url.URL
+	seen   map[string]bool
+	assets []string
+}
+
+func newAssetCollector(base *url.URL) *assetCollector {
+	return &assetCollector{base: base, seen: make(map[string]bool)}
+}
+
+// add resolves a reference against the base URL and keeps it if it's a new http(s) URL
+func (a *assetCollector) add(ref string) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") || strings.HasPrefix(strings.ToLower(ref), "data:") {
+		return
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		log.Debug().Err(err).Str("ref", ref).Msg("Failed to parse asset URL")
+		return
+	}
+	abs := a.base.ResolveReference(u)
+	if abs.Scheme != "http" && abs.Scheme != "https" {
+		return
+	}
+	abs.Fragment = ""
+	abs.RawFragment = ""
+	if s := abs.String(); !a.seen[s] {
+		a.seen[s] = true
+		a.assets = append(a.assets, s)
+	}
+}
+
+// addSrcset adds each candidate URL of a srcset attribute
+func (a *assetCollector) addSrcset(srcset string) {
+	for _, ref := range parseSrcset(srcset) {
+		a.add(ref)
+	}
+}
+
+// addCSS adds the url() and @import references in a stylesheet
+func (a *assetCollector) addCSS(css string) {
+	for _, match := range cssURLPattern.FindAllStringSubmatch(css, -1) {
+		for _, ref := range match[1:] {
+			if ref != "" {
+				a.add(ref)
+				break
+			}
+		}
+	}
+}
+
+// parseSrcset returns the URLs of the image candidates in a srcset. Each
+// candidate is a URL followed by optional descriptors, and candidates are
+// separated by commas, which URLs themselves may contain.
+func parseSrcset(srcset string) []string {
+	var urls []string
+	s := srcset
+	for {
+		s = strings.TrimLeft(s, " \t\n\r\f,")
+		if s == "" {
+			return urls
+		}
+		end := strings.IndexAny(s, " \t\n\r\f")
+		if end < 0 {
+			end = len(s)
+		}
+		candidate := s[:end]
+		s = s[end:]
+
+		if strings.HasSuffix(candidate, ",") {
+			// A URL ending in commas has no descriptors
+			candidate = strings.TrimRight(candidate, ",")
+		} else if i := strings.IndexByte(s, ','); i >= 0 {
+			s = s[i+1:]
+		} else {
+			s = ""
+		}
+		if candidate != "" {
+			urls = append(urls, candidate)
+		}
+	}
+}
+
+// extractAssets returns the absolute URLs of the stylesheets, scripts, images,
+// icons and media an HTML page references, including url() references in its
+// inline styles
+func extractAssets(body []byte, base string) []string {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("base_url", base).
+			Msg("Failed to parse base URL for asset extraction")
+		return nil
+	}
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("base_url", base).
+			Msg("Failed to parse HTML for asset extraction")
+		return nil
+	}
+
+	assets := newAssetCollector(baseURL)
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "img":
+				assets.add(attrValue(n, "src"))
+				assets.addSrcset(attrValue(n, "srcset"))
+			case "source":
+				// <picture> sources use srcset, <video> and <audio> sources use src
+				assets.add(attrValue(n, "src"))
+				assets.addSrcset(attrValue(n, "srcset"))
+			case "video":
+				assets.add(attrValue(n, "src"))
+				assets.add(attrValue(n, "poster"))
+			case "audio", "script":
+				assets.add(attrValue(n, "src"))
+			case "link":
+				for _, rel := range strings.Fields(strings.ToLower(attrValue(n, "rel"))) {
+					if assetLinkRels[rel] {
+						assets.add(attrValue(n, "href"))
+						assets.addSrcset(attrValue(n, "imagesrcset"))
+						break
+					}
+				}
+			case "style":
+				if n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+					assets.addCSS(n.FirstChild.Data)
+				}
+			}
+			if style := attrValue(n, "style"); style != "" {
+				assets.addCSS(style)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+
+	log.Debug().
+		Str("base_url", base).
+		Int("assets_found", len(assets.assets)).
+		Msg("HTML asset extraction completed")
+
+	return assets.assets
+}
+
+// extractCSSAssets returns the absolute URLs of the fonts, images and imported
+// stylesheets a stylesheet references
+func extractCSSAssets(body []byte, base string) []string {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return nil
+	}
+	assets := newAssetCollector(baseURL)
+	assets.addCSS(string(body))
+	return assets.assets
+}
+
+// attrValue returns the value of an element's attribute, or "" when it isn't set
+func attrValue(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestExtractAssets(t *testing.T) {
+	body := []byte(`<!DOCTYPE html>
+<html>
+<head>
+	<link rel="stylesheet" href="/css/site.css">
+	<link rel="preload" as="image" href="/img/hero.webp" imagesrcset="/img/hero-800.webp 800w, /img/hero-1600.webp 1600w">
+	<link rel="shortcut icon" href="/favicon.ico">
+	<link rel="canonical" href="/page">
+	<script src="https://cdn.example.net/js/app.js"></script>
+	<script>var inline = true;</script>
+	<style>.banner { background: url('/img/banner.png') }</style>
+</head>
+<body>
+	<img src="/img/logo.svg" srcset="/img/logo.png 1x, /img/logo@2x.png 2x">
+	<picture>
+		<source srcset="/img/photo.avif" type="image/avif">
+		<img src="/img/photo.jpg">
+	</picture>
+	<video poster="/media/poster.jpg"><source src="/media/clip.mp4" type="video/mp4"></video>
+	<audio><source src="/media/track.mp3"></audio>
+	<div style="background-image: url(&quot;/img/tile.png&quot;)"></div>
+	<img src="data:image/gif;base64,R0lGODlhAQABAAAAACw=">
+	<img src="/img/logo.svg#dup">
+	<a href="/about">About</a>
+</body>
+</html>`)
+
+	got := extractAssets(body, "https://example.com/blog/post")
+	want := []string{
+		"https://example.com/css/site.css",
+		"https://example.com/img/hero.webp",
+		"https://example.com/img/hero-800.webp",
+		"https://example.com/img/hero-1600.webp",
+		"https://example.com/favicon.ico",
+		"https://cdn.example.net/js/app.js",
+		"https://example.com/img/banner.png",
+		"https://example.com/img/logo.svg",
+		"https://example.com/img/logo.png",
+		"https://example.com/img/logo@2x.png",
+		"https://example.com/img/photo.avif",
+		"https://example.com/img/photo.jpg",
+		"https://example.com/media/poster.jpg",
+		"https://example.com/media/clip.mp4",
+		"https://example.com/media/track.mp3",
+		"https://example.com/img/tile.png",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("extractAssets =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestParseSrcset(t *testing.T) {
+	tests := map[string][]string{
+		"a.jpg":                        {"a.jpg"},
+		"a.jpg 1x, b.jpg 2x":           {"a.jpg", "b.jpg"},
+		"a.jpg 480w,b.jpg 800w":        {"a.jpg", "b.jpg"},
+		"/img/w_100,h_50/a.jpg 100w":   {"/img/w_100,h_50/a.jpg"},
+		"a.jpg,b.jpg":                  {"a.jpg,b.jpg"},
+		"a.jpg, b.jpg":                 {"a.jpg", "b.jpg"},
+		"  a.jpg  1.5x ,\n b.jpg 3x  ": {"a.jpg", "b.jpg"},
+		"":                             nil,
+	}
+	for srcset, want := range tests {
+		if got := parseSrcset(srcset); !reflect.DeepEqual(got, want) {
+			t.Errorf("parseSrcset(%q) = %v, want %v", srcset, got, want)
+		}
+	}
+}
+
+func TestWarmURLFindsStylesheetAssets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		w.Write([]byte(`@import "base.css";
+@font-face { font-family: Inter; src: url(../fonts/inter.woff2) format("woff2"), url("../fonts/inter.woff") format("woff"); }
+.icon { background: url( 'icons.svg#star' ) }
+.mask { filter: url(#blur) }
+.tiny { background: url(data:image/png;base64,iVBORw0KGgo=) }`))
+	}))
+	defer server.Close()
+
+	c := New(DefaultConfig())
+	res, err := c.WarmURLWithOptions(context.Background(), server.URL+"/css/site.css", WarmOptions{FindAssets: true})
+	if err != nil {
+		t.Fatalf("warm: %v", err)
+	}
+	want := []string{
+		server.URL + "/css/base.css",
+		server.URL + "/fonts/inter.woff2",
+		server.URL + "/fonts/inter.woff",
+		server.URL + "/css/icons.svg",
+	}
+	if !reflect.DeepEqual(res.Assets, want) {
+		t.Errorf("Assets = %v, want %v", res.Assets, want)
+	}
+}
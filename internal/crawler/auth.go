@@ -0,0 +1,161 @@
+package crawler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// redacted replaces secret values in logs and API responses
+const redacted = "[REDACTED]"
+
+// RequestAuth holds the credentials sent with every request for a protected site.
+// Values are secret: JSON encoding and String redact them, use MarshalSecret to
+// get the real values for encrypted storage.
+type RequestAuth struct {
+	Domain   string            `json:"domain,omitempty"`   // Credentials are only sent to this domain and its subdomains
+	Headers  map[string]string `json:"headers,omitempty"`  // Extra headers such as a shared-secret header
+	Username string            `json:"username,omitempty"` // Basic auth username
+	Password string            `json:"password,omitempty"` // Basic auth password
+	Cookies  map[string]string `json:"cookies,omitempty"`  // Cookies seeding the jar requests are sent with
+}
+
+// requestAuthSecret has RequestAuth's fields without its redacting methods
+type requestAuthSecret RequestAuth
+
+// IsEmpty reports whether there are no credentials to send
+func (a *RequestAuth) IsEmpty() bool {
+	return a == nil || (len(a.Headers) == 0 && a.Username == "" && a.Password == "" && len(a.Cookies) == 0)
+}
+
+// AppliesTo reports whether the credentials should be sent to the host.
+// Credentials without a domain apply everywhere.
+func (a *RequestAuth) AppliesTo(host string) bool {
+	if a.IsEmpty() {
+		return false
+	}
+	if a.Domain == "" {
+		return true
+	}
+	host = strings.ToLower(host)
+	domain := strings.ToLower(a.Domain)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// Apply adds the headers and basic auth to the request if it is for the auth
+// domain. Cookies are sent by the client's jar, see NewCookieJar.
+func (a *RequestAuth) Apply(req *http.Request) {
+	if !a.AppliesTo(req.URL.Hostname()) {
+		return
+	}
+	for name, value := range a.Headers {
+		req.Header.Set(name, value)
+	}
+	if a.Username != "" || a.Password != "" {
+		req.SetBasicAuth(a.Username, a.Password)
+	}
+}
+
+// CheckRedirect is an http.Client CheckRedirect that removes the auth headers
+// from redirects to hosts the credentials don't apply to. net/http copies
+// custom headers to every redirect, and only strips Authorization and Cookie.
+func (a *RequestAuth) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if a != nil && !a.AppliesTo(req.URL.Hostname()) {
+		for name := range a.Headers {
+			req.Header.Del(name)
+		}
+	}
+	return nil
+}
+
+// authJar is a cookie jar seeded with the auth cookies the first time a host
+// they apply to is requested. Cookies the site sets replace the seeded ones.
+type authJar struct {
+	*cookiejar.Jar
+	auth *RequestAuth
+
+	mu     sync.Mutex
+	seeded map[string]bool
+}
+
+// NewCookieJar returns a cookie jar seeded with the auth cookies, which keeps
+// the cookies the site sets across requests, or nil when there are no credentials
+func NewCookieJar(auth *RequestAuth) http.CookieJar {
+	if auth.IsEmpty() {
+		return nil
+	}
+	jar, _ := cookiejar.New(nil) // Only fails for an invalid public suffix list
+	return &authJar{Jar: jar, auth: auth, seeded: make(map[string]bool)}
+}
+
+// Cookies returns the cookies to send to the URL, seeding its host first
+func (j *authJar) Cookies(u *url.URL) []*http.Cookie {
+	host := strings.ToLower(u.Hostname())
+	if len(j.auth.Cookies) > 0 && j.auth.AppliesTo(host) {
+		j.mu.Lock()
+		if !j.seeded[host] {
+			j.seeded[host] = true
+			cookies := make([]*http.Cookie, 0, len(j.auth.Cookies))
+			for name, value := range j.auth.Cookies {
+				cookies = append(cookies, &http.Cookie{Name: name, Value: value, Path: "/"})
+			}
+			j.Jar.SetCookies(u, cookies)
+		}
+		j.mu.Unlock()
+	}
+	return j.Jar.Cookies(u)
+}
+
+// MarshalSecret encodes the credentials with their real values
+func (a RequestAuth) MarshalSecret() ([]byte, error) {
+	return json.Marshal(requestAuthSecret(a))
+}
+
+// MarshalJSON encodes the credentials with secret values redacted
+func (a RequestAuth) MarshalJSON() ([]byte, error) {
+	out := requestAuthSecret{
+		Domain:   a.Domain,
+		Headers:  redactValues(a.Headers),
+		Username: a.Username,
+		Cookies:  redactValues(a.Cookies),
+	}
+	if a.Password != "" {
+		out.Password = redacted
+	}
+	return json.Marshal(out)
+}
+
+// String describes the credentials without their secret values
+func (a RequestAuth) String() string {
+	return fmt.Sprintf("RequestAuth{domain: %s, headers: %v, username: %s, cookies: %v}",
+		a.Domain, sortedKeys(a.Headers), a.Username, sortedKeys(a.Cookies))
+}
+
+func redactValues(values map[string]string) map[string]string {
+	if len(values) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(values))
+	for name := range values {
+		out[name] = redacted
+	}
+	return out
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,164 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestWarmURLAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		cookie, err := r.Cookie("session")
+		if !ok || user != "preview" || pass != "secret" || err != nil || cookie.Value != "xyz" || r.Header.Get("X-Preview-Token") != "abc123" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("welcome"))
+	}))
+	defer server.Close()
+
+	auth := &RequestAuth{
+		Domain:   "127.0.0.1",
+		Headers:  map[string]string{"X-Preview-Token": "abc123"},
+		Username: "preview",
+		Password: "secret",
+		Cookies:  map[string]string{"session": "xyz"},
+	}
+
+	c := New(DefaultConfig())
+	res, err := c.WarmURLWithOptions(context.Background(), server.URL, WarmOptions{Auth: auth})
+	if err != nil {
+		t.Fatalf("warm with auth: %v", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	// Credentials for another domain must not be sent
+	other := *auth
+	other.Domain = "example.com"
+	res, err = c.WarmURLWithOptions(context.Background(), server.URL, WarmOptions{Auth: &other})
+	if err == nil || res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, err = %v, want 401 error", res.StatusCode, err)
+	}
+}
+
+func TestRequestAuthAppliesTo(t *testing.T) {
+	auth := &RequestAuth{Domain: "example.com", Username: "u"}
+	tests := map[string]bool{
+		"example.com":     true,
+		"www.example.com": true,
+		"EXAMPLE.COM":     true,
+		"notexample.com":  false,
+		"example.com.au":  false,
+	}
+	for host, want := range tests {
+		if got := auth.AppliesTo(host); got != want {
+			t.Errorf("AppliesTo(%q) = %v, want %v", host, got, want)
+		}
+	}
+
+	var empty *RequestAuth
+	if empty.AppliesTo("example.com") {
+		t.Error("nil auth should not apply")
+	}
+}
+
+func TestRequestAuthRedaction(t *testing.T) {
+	auth := RequestAuth{
+		Domain:   "example.com",
+		Headers:  map[string]string{"X-Token": "header-secret"},
+		Username: "user",
+		Password: "pass-secret",
+		Cookies:  map[string]string{"session": "cookie-secret"},
+	}
+
+	encoded, err := json.Marshal(auth)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	for _, secret := range []string{"header-secret", "pass-secret", "cookie-secret"} {
+		if strings.Contains(string(encoded), secret) {
+			t.Errorf("JSON %s leaks %q", encoded, secret)
+		}
+		if strings.Contains(auth.String(), secret) {
+			t.Errorf("String %s leaks %q", auth.String(), secret)
+		}
+	}
+
+	secret, err := auth.MarshalSecret()
+	if err != nil {
+		t.Fatalf("marshal secret: %v", err)
+	}
+	var decoded RequestAuth
+	if err := json.Unmarshal(secret, &decoded); err != nil {
+		t.Fatalf("unmarshal secret: %v", err)
+	}
+	if decoded.Password != "pass-secret" || decoded.Headers["X-Token"] != "header-secret" || decoded.Cookies["session"] != "cookie-secret" {
+		t.Errorf("secret round trip = %+v", decoded)
+	}
+}
+
+func TestWarmURLAuthRedirect(t *testing.T) {
+	var leaked string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = r.Header.Get("X-Preview-Token")
+		w.Write([]byte("elsewhere"))
+	}))
+	defer other.Close()
+	// The same server under another host name, which the auth doesn't apply to
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, otherURL+"/landing", http.StatusFound)
+	}))
+	defer server.Close()
+
+	auth := &RequestAuth{Domain: "127.0.0.1", Headers: map[string]string{"X-Preview-Token": "abc123"}}
+	c := New(DefaultConfig())
+	if _, err := c.WarmURLWithOptions(context.Background(), server.URL, WarmOptions{Auth: auth}); err != nil {
+		t.Fatalf("warm: %v", err)
+	}
+	if leaked != "" {
+		t.Errorf("auth header sent to another host after a redirect: %q", leaked)
+	}
+}
+
+func TestWarmURLCookieJar(t *testing.T) {
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var names []string
+		for _, cookie := range r.Cookies() {
+			names = append(names, cookie.Name+"="+cookie.Value)
+		}
+		sort.Strings(names)
+		seen = append(seen, strings.Join(names, ";"))
+		http.SetCookie(w, &http.Cookie{Name: "visit", Value: "1", Path: "/"})
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	auth := &RequestAuth{Domain: "127.0.0.1", Cookies: map[string]string{"session": "xyz"}}
+	jar := NewCookieJar(auth)
+	c := New(DefaultConfig())
+	for i := 0; i < 2; i++ {
+		if _, err := c.WarmURLWithOptions(context.Background(), server.URL, WarmOptions{Auth: auth, Jar: jar}); err != nil {
+			t.Fatalf("warm: %v", err)
+		}
+	}
+
+	want := []string{"session=xyz", "session=xyz;visit=1"}
+	if strings.Join(seen, "|") != strings.Join(want, "|") {
+		t.Errorf("cookies sent = %v, want %v", seen, want)
+	}
+
+	if NewCookieJar(nil) != nil {
+		t.Error("expected no jar without auth")
+	}
+}
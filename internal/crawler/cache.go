@@ -0,0 +1,406 @@
+package crawler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CacheStatus is a normalised cache status that can be compared across CDNs
+type CacheStatus string
+
+const (
+	CacheStatusHit         CacheStatus = "HIT"
+	CacheStatusMiss        CacheStatus = "MISS"
+	CacheStatusStale       CacheStatus = "STALE"
+	CacheStatusExpired     CacheStatus = "EXPIRED"
+	CacheStatusRevalidated CacheStatus = "REVALIDATED"
+	CacheStatusBypass      CacheStatus = "BYPASS"
+	CacheStatusDynamic     CacheStatus = "DYNAMIC"
+	CacheStatusUnknown     CacheStatus = "UNKNOWN"
+)
+
+// CacheResult is the cache status detected from a response
+type CacheResult struct {
+	Status CacheStatus // Normalised cache status
+	Raw    string      // Raw header value the status was derived from
+	CDN    string      // Name of the CDN that reported the status
+}
+
+// CacheDetector recognises the cache status headers of a specific CDN
+type CacheDetector interface {
+	// Name returns the CDN name recorded against detected results
+	Name() string
+	// PrepareRequest adds any debug headers the CDN needs to report a cache status
+	PrepareRequest(req *http.Request)
+	// Detect returns the cache status if the response headers came from this CDN
+	Detect(header http.Header) (CacheResult, bool)
+}
+
+// CDNIdentifier is implemented by detectors whose CDN only reports a cache status
+// when asked with debug headers. Identify recognises the CDN from headers it always
+// sends, so the debug headers only go to sites behind it.
+type CDNIdentifier interface {
+	Identify(header http.Header) bool
+}
+
+var (
+	detectorsMu sync.RWMutex
+	detectors   []CacheDetector
+)
+
+func init() {
+	// Order matters: detectors that identify a CDN by specific headers run
+	// before the generic ones that share header names (X-Cache, Cache-Status)
+	RegisterCacheDetector(cloudflareDetector{})
+	RegisterCacheDetector(akamaiDetector{})
+	RegisterCacheDetector(cloudFrontDetector{})
+	RegisterCacheDetector(fastlyDetector{})
+	RegisterCacheDetector(vercelDetector{})
+	RegisterCacheDetector(netlifyDetector{})
+	RegisterCacheDetector(varnishDetector{})
+	RegisterCacheDetector(cacheStatusDetector{})
+	RegisterCacheDetector(xCacheDetector{})
+}
+
+// RegisterCacheDetector adds a detector to the registry.
+// Detectors are consulted in registration order and the first match wins.
+func RegisterCacheDetector(d CacheDetector) {
+	detectorsMu.Lock()
+	defer detectorsMu.Unlock()
+	detectors = append(detectors, d)
+}
+
+// CacheDetectors returns the registered detectors in the order they are consulted
+func CacheDetectors() []CacheDetector {
+	detectorsMu.RLock()
+	defer detectorsMu.RUnlock()
+	return append([]CacheDetector(nil), detectors...)
+}
+
+// DetectCacheStatus returns the normalised cache status reported by the response headers.
+// If no detector recognises the headers the status is UNKNOWN with an empty CDN.
+func DetectCacheStatus(header http.Header) CacheResult {
+	for _, d := range CacheDetectors() {
+		if result, ok := d.Detect(header); ok {
+			result.CDN = d.Name()
+			return result
+		}
+	}
+
+	// Keep the raw value of an unrecognised X-Cache header for debugging
+	return CacheResult{Status: CacheStatusUnknown, Raw: header.Get("X-Cache")}
+}
+
+// IdentifyCDN returns the detector for the CDN that served a response, recognised
+// from the cache status it reported or from headers the CDN always sends. It
+// returns nil when no CDN is recognised.
+func IdentifyCDN(header http.Header) CacheDetector {
+	for _, d := range CacheDetectors() {
+		if _, ok := d.Detect(header); ok {
+			return d
+		}
+		if id, ok := d.(CDNIdentifier); ok && id.Identify(header) {
+			return d
+		}
+	}
+	return nil
+}
+
+// PrepareCacheRequest lets every registered detector add its debug request headers
+func PrepareCacheRequest(req *http.Request) {
+	for _, d := range CacheDetectors() {
+		d.PrepareRequest(req)
+	}
+}
+
+// normaliseCacheStatus maps a single CDN status token to a CacheStatus
+func normaliseCacheStatus(value string) CacheStatus {
+	token := strings.ToUpper(strings.TrimSpace(value))
+	// Values such as "Hit from cloudfront" or "TCP_HIT from a23-..." carry the status first
+	if i := strings.IndexByte(token, ' '); i != -1 {
+		token = token[:i]
+	}
+	token = strings.TrimPrefix(token, "TCP_")
+
+	switch token {
+	case "HIT", "MEM_HIT", "IMS_HIT", "PRERENDER":
+		return CacheStatusHit
+	case "MISS":
+		return CacheStatusMiss
+	case "STALE", "UPDATING", "STALE_HIT", "HIT-STALE":
+		return CacheStatusStale
+	case "EXPIRED", "REFRESH_MISS":
+		return CacheStatusExpired
+	case "REVALIDATED", "REFRESHHIT", "REFRESH_HIT":
+		return CacheStatusRevalidated
+	case "BYPASS", "PASS":
+		return CacheStatusBypass
+	case "DYNAMIC", "NONE":
+		return CacheStatusDynamic
+	default:
+		return CacheStatusUnknown
+	}
+}
+
+// lastListValue returns the last member of a comma-separated header value.
+// Multi-tier CDNs append one value per tier, with the tier closest to the client last.
+func lastListValue(value string) string {
+	parts := strings.Split(value, ",")
+	return strings.TrimSpace(parts[len(parts)-1])
+}
+
+// cloudflareDetector reads Cloudflare's CF-Cache-Status header
+type cloudflareDetector struct{}
+
+func (cloudflareDetector) Name() string { return "cloudflare" }
+
+func (cloudflareDetector) PrepareRequest(*http.Request) {}
+
+func (cloudflareDetector) Detect(header http.Header) (CacheResult, bool) {
+	raw := header.Get("CF-Cache-Status")
+	if raw == "" {
+		return CacheResult{}, false
+	}
+	return CacheResult{Status: normaliseCacheStatus(raw), Raw: raw}, true
+}
+
+// fastlyDetector reads X-Cache on responses served by Fastly cache nodes
+type fastlyDetector struct{}
+
+func (fastlyDetector) Name() string { return "fastly" }
+
+func (fastlyDetector) PrepareRequest(req *http.Request) {
+	req.Header.Set("Fastly-Debug", "1")
+}
+
+func (fastlyDetector) Detect(header http.Header) (CacheResult, bool) {
+	raw := header.Get("X-Cache")
+	if raw == "" {
+		return CacheResult{}, false
+	}
+	if !strings.Contains(header.Get("X-Served-By"), "cache-") && header.Get("Fastly-Debug-Path") == "" {
+		return CacheResult{}, false
+	}
+	return CacheResult{Status: normaliseCacheStatus(lastListValue(raw)), Raw: raw}, true
+}
+
+func (fastlyDetector) Identify(header http.Header) bool {
+	return strings.Contains(header.Get("X-Served-By"), "cache-") || header.Get("X-Fastly-Request-Id") != ""
+}
+
+// akamaiDetector reads Akamai's debug X-Cache and X-Cache-Remote headers
+type akamaiDetector struct{}
+
+func (akamaiDetector) Name() string { return "akamai" }
+
+func (akamaiDetector) PrepareRequest(req *http.Request) {
+	req.Header.Add("Pragma", "akamai-x-cache-on, akamai-x-cache-remote-on, akamai-x-check-cacheable, akamai-x-get-cache-key")
+}
+
+func (akamaiDetector) Detect(header http.Header) (CacheResult, bool) {
+	// X-Cache describes the edge, X-Cache-Remote the parent tier
+	raw := header.Get("X-Cache")
+	if !strings.HasPrefix(strings.ToUpper(raw), "TCP_") {
+		raw = header.Get("X-Cache-Remote")
+	}
+	if raw == "" {
+		return CacheResult{}, false
+	}
+
+	status := normaliseCacheStatus(raw)
+	if status == CacheStatusMiss && strings.EqualFold(header.Get("X-Check-Cacheable"), "NO") {
+		status = CacheStatusDynamic
+	}
+	return CacheResult{Status: status, Raw: raw}, true
+}
+
+func (akamaiDetector) Identify(header http.Header) bool {
+	return header.Get("Akamai-GRN") != "" ||
+		header.Get("X-Akamai-Transformed") != "" ||
+		strings.Contains(header.Get("Server"), "AkamaiGHost")
+}
+
+// cloudFrontDetector reads X-Cache values such as "Hit from cloudfront"
+type cloudFrontDetector struct{}
+
+func (cloudFrontDetector) Name() string { return "cloudfront" }
+
+func (cloudFrontDetector) PrepareRequest(*http.Request) {}
+
+func (cloudFrontDetector) Detect(header http.Header) (CacheResult, bool) {
+	raw := header.Get("X-Cache")
+	if !strings.Contains(strings.ToLower(raw), "cloudfront") {
+		return CacheResult{}, false
+	}
+	return CacheResult{Status: normaliseCacheStatus(raw), Raw: raw}, true
+}
+
+// vercelDetector reads Vercel's x-vercel-cache header
+type vercelDetector struct{}
+
+func (vercelDetector) Name() string { return "vercel" }
+
+func (vercelDetector) PrepareRequest(*http.Request) {}
+
+func (vercelDetector) Detect(header http.Header) (CacheResult, bool) {
+	raw := header.Get("X-Vercel-Cache")
+	if raw == "" {
+		return CacheResult{}, false
+	}
+	return CacheResult{Status: normaliseCacheStatus(raw), Raw: raw}, true
+}
+
+// netlifyDetector reads the "Netlify Edge" member of the Cache-Status header
+type netlifyDetector struct{}
+
+func (netlifyDetector) Name() string { return "netlify" }
+
+func (netlifyDetector) PrepareRequest(*http.Request) {}
+
+func (netlifyDetector) Detect(header http.Header) (CacheResult, bool) {
+	raw := header.Get("Cache-Status")
+	if raw == "" || (header.Get("X-Nf-Request-Id") == "" && !strings.Contains(raw, "Netlify")) {
+		return CacheResult{}, false
+	}
+
+	entries := parseCacheStatusHeader(raw)
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].cache == "Netlify Edge" {
+			return CacheResult{Status: entries[i].status(), Raw: raw}, true
+		}
+	}
+	if len(entries) == 0 {
+		return CacheResult{}, false
+	}
+	return CacheResult{Status: entries[len(entries)-1].status(), Raw: raw}, true
+}
+
+// varnishDetector infers the cache status from Varnish transaction IDs
+type varnishDetector struct{}
+
+func (varnishDetector) Name() string { return "varnish" }
+
+func (varnishDetector) PrepareRequest(*http.Request) {}
+
+func (varnishDetector) Detect(header http.Header) (CacheResult, bool) {
+	raw := header.Get("X-Varnish")
+	if raw == "" {
+		return CacheResult{}, false
+	}
+
+	// Two transaction IDs mean the response was served from an earlier request
+	if len(strings.Fields(raw)) > 1 {
+		return CacheResult{Status: CacheStatusHit, Raw: raw}, true
+	}
+	return CacheResult{Status: CacheStatusMiss, Raw: raw}, true
+}
+
+// cacheStatusDetector reads the standard RFC 9211 Cache-Status header
+type cacheStatusDetector struct{}
+
+func (cacheStatusDetector) Name() string { return "cache-status" }
+
+func (cacheStatusDetector) PrepareRequest(*http.Request) {}
+
+func (cacheStatusDetector) Detect(header http.Header) (CacheResult, bool) {
+	raw := header.Get("Cache-Status")
+	entries := parseCacheStatusHeader(raw)
+	if len(entries) == 0 {
+		return CacheResult{}, false
+	}
+	// The last member is the cache closest to the client
+	return CacheResult{Status: entries[len(entries)-1].status(), Raw: raw}, true
+}
+
+// xCacheDetector reads a plain X-Cache header such as "HIT" or "MISS" from caches
+// the CDN-specific detectors don't recognise
+type xCacheDetector struct{}
+
+func (xCacheDetector) Name() string { return "x-cache" }
+
+func (xCacheDetector) PrepareRequest(*http.Request) {}
+
+func (xCacheDetector) Detect(header http.Header) (CacheResult, bool) {
+	raw := header.Get("X-Cache")
+	if raw == "" {
+		return CacheResult{}, false
+	}
+	// Caches in front of each other append their own status, the closest last
+	status := normaliseCacheStatus(lastListValue(raw))
+	if status == CacheStatusUnknown {
+		return CacheResult{}, false
+	}
+	return CacheResult{Status: status, Raw: raw}, true
+}
+
+// cacheStatusEntry is a single member of an RFC 9211 Cache-Status header
+type cacheStatusEntry struct {
+	cache  string
+	params map[string]string
+}
+
+// status maps the entry's parameters to a CacheStatus
+func (e cacheStatusEntry) status() CacheStatus {
+	if _, ok := e.params["hit"]; ok {
+		if ttl, err := strconv.Atoi(e.params["ttl"]); err == nil && ttl < 0 {
+			return CacheStatusStale
+		}
+		return CacheStatusHit
+	}
+
+	switch e.params["fwd"] {
+	case "stale":
+		if e.params["fwd-status"] == "304" {
+			return CacheStatusRevalidated
+		}
+		return CacheStatusExpired
+	case "bypass", "method", "request":
+		return CacheStatusBypass
+	case "uri-miss", "vary-miss", "miss", "partial":
+		return CacheStatusMiss
+	}
+
+	// Netlify and others also send bare "hit"/"miss" style tokens
+	return normaliseCacheStatus(e.cache)
+}
+
+// parseCacheStatusHeader splits an RFC 9211 Cache-Status header into its members
+func parseCacheStatusHeader(value string) []cacheStatusEntry {
+	var entries []cacheStatusEntry
+	for _, member := range splitOutsideQuotes(value, ',') {
+		parts := splitOutsideQuotes(member, ';')
+		name := strings.Trim(strings.TrimSpace(parts[0]), `"`)
+		if name == "" {
+			continue
+		}
+
+		entry := cacheStatusEntry{cache: name, params: make(map[string]string)}
+		for _, param := range parts[1:] {
+			key, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+			entry.params[strings.ToLower(key)] = strings.Trim(val, `"`)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// splitOutsideQuotes splits s on sep, ignoring separators inside double quotes
+func splitOutsideQuotes(s string, sep byte) []string {
+	var parts []string
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case sep:
+			if !inQuotes {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
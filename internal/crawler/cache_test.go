@@ -0,0 +1,118 @@
+package crawler
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDetectCacheStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus CacheStatus
+		wantCDN    string
+	}{
+		{"cloudflare hit", map[string]string{"CF-Cache-Status": "HIT"}, CacheStatusHit, "cloudflare"},
+		{"cloudflare updating", map[string]string{"CF-Cache-Status": "UPDATING"}, CacheStatusStale, "cloudflare"},
+		{"cloudflare dynamic", map[string]string{"CF-Cache-Status": "DYNAMIC"}, CacheStatusDynamic, "cloudflare"},
+		{"fastly edge hit behind shield miss", map[string]string{
+			"X-Served-By": "cache-syd10131-SYD, cache-akl10321-AKL",
+			"X-Cache":     "MISS, HIT",
+		}, CacheStatusHit, "fastly"},
+		{"fastly pass", map[string]string{
+			"X-Served-By": "cache-syd10131-SYD",
+			"X-Cache":     "PASS",
+		}, CacheStatusBypass, "fastly"},
+		{"akamai edge hit", map[string]string{"X-Cache": "TCP_MEM_HIT from a23-45-67-89.deploy.akamaitechnologies.com (AkamaiGHost/10.0)"}, CacheStatusHit, "akamai"},
+		{"akamai refresh hit", map[string]string{"X-Cache": "TCP_REFRESH_HIT from a23-45-67-89"}, CacheStatusRevalidated, "akamai"},
+		{"akamai uncacheable miss", map[string]string{
+			"X-Cache":           "TCP_MISS from a23-45-67-89",
+			"X-Check-Cacheable": "NO",
+		}, CacheStatusDynamic, "akamai"},
+		{"akamai remote only", map[string]string{"X-Cache-Remote": "TCP_MISS from a23-45-67-90"}, CacheStatusMiss, "akamai"},
+		{"cloudfront hit", map[string]string{"X-Cache": "Hit from cloudfront"}, CacheStatusHit, "cloudfront"},
+		{"cloudfront refresh", map[string]string{"X-Cache": "RefreshHit from cloudfront"}, CacheStatusRevalidated, "cloudfront"},
+		{"vercel stale", map[string]string{"X-Vercel-Cache": "STALE"}, CacheStatusStale, "vercel"},
+		{"vercel prerender", map[string]string{"X-Vercel-Cache": "PRERENDER"}, CacheStatusHit, "vercel"},
+		{"netlify edge hit", map[string]string{
+			"Cache-Status":    `"Netlify Durable"; fwd=miss, "Netlify Edge"; hit`,
+			"X-Nf-Request-Id": "01H",
+		}, CacheStatusHit, "netlify"},
+		{"netlify edge miss", map[string]string{
+			"Cache-Status": `"Netlify Edge"; fwd=uri-miss; stored`,
+		}, CacheStatusMiss, "netlify"},
+		{"varnish hit", map[string]string{"X-Varnish": "32770 32768"}, CacheStatusHit, "varnish"},
+		{"varnish miss", map[string]string{"X-Varnish": "32770"}, CacheStatusMiss, "varnish"},
+		{"rfc 9211 hit", map[string]string{"Cache-Status": "ReverseProxy; fwd=uri-miss, ExampleCDN; hit; ttl=300"}, CacheStatusHit, "cache-status"},
+		{"rfc 9211 stale hit", map[string]string{"Cache-Status": "ExampleCDN; hit; ttl=-10"}, CacheStatusStale, "cache-status"},
+		{"rfc 9211 revalidated", map[string]string{"Cache-Status": "ExampleCDN; fwd=stale; fwd-status=304"}, CacheStatusRevalidated, "cache-status"},
+		{"rfc 9211 bypass", map[string]string{"Cache-Status": "ExampleCDN; fwd=bypass"}, CacheStatusBypass, "cache-status"},
+		{"no headers", map[string]string{}, CacheStatusUnknown, ""},
+		{"generic x-cache hit", map[string]string{"X-Cache": "HIT"}, CacheStatusHit, "x-cache"},
+		{"generic x-cache tiers", map[string]string{"X-Cache": "HIT, MISS"}, CacheStatusMiss, "x-cache"},
+		{"unrecognised x-cache", map[string]string{"X-Cache": "maybe"}, CacheStatusUnknown, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for k, v := range tt.headers {
+				header.Set(k, v)
+			}
+
+			result := DetectCacheStatus(header)
+			if result.Status != tt.wantStatus {
+				t.Errorf("DetectCacheStatus() status = %s, want %s", result.Status, tt.wantStatus)
+			}
+			if result.CDN != tt.wantCDN {
+				t.Errorf("DetectCacheStatus() cdn = %q, want %q", result.CDN, tt.wantCDN)
+			}
+		})
+	}
+}
+
+func TestIdentifyCDN(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		wantCDN string
+	}{
+		{"akamai server", map[string]string{"Server": "AkamaiGHost"}, "akamai"},
+		{"akamai grn", map[string]string{"Akamai-GRN": "0.1a2b3c4d.1700000000.5e6f"}, "akamai"},
+		{"fastly request id", map[string]string{"X-Fastly-Request-Id": "abc"}, "fastly"},
+		{"cloudflare status", map[string]string{"CF-Cache-Status": "HIT"}, "cloudflare"},
+		{"origin", map[string]string{"Server": "nginx"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for k, v := range tt.headers {
+				header.Set(k, v)
+			}
+			var got string
+			if d := IdentifyCDN(header); d != nil {
+				got = d.Name()
+			}
+			if got != tt.wantCDN {
+				t.Errorf("IdentifyCDN() = %q, want %q", got, tt.wantCDN)
+			}
+		})
+	}
+}
+
+func TestPrepareCacheRequest(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	PrepareCacheRequest(req)
+
+	if req.Header.Get("Fastly-Debug") != "1" {
+		t.Error("Expected Fastly-Debug header to be set")
+	}
+	if !strings.Contains(req.Header.Get("Pragma"), "akamai-x-cache-on") {
+		t.Errorf("Expected Akamai Pragma debug headers, got %q", req.Header.Get("Pragma"))
+	}
+}
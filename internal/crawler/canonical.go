@@ -0,0 +1,239 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Trailing slash policies
+const (
+	TrailingSlashKeep   = "keep"   // Paths are left as they were found
+	TrailingSlashAdd    = "add"    // Directory-like paths end with a slash
+	TrailingSlashRemove = "remove" // Paths other than the root never end with a slash
+)
+
+// DefaultTrackingParams are the query parameters removed from URLs when a
+// policy doesn't list its own. A trailing * matches any parameter with the prefix.
+var DefaultTrackingParams = []string{
+	"utm_*",
+	"gclid",
+	"gbraid",
+	"wbraid",
+	"dclid",
+	"fbclid",
+	"msclkid",
+	"yclid",
+	"twclid",
+	"igshid",
+	"mc_cid",
+	"mc_eid",
+	"_ga",
+	"_gl",
+	"_hsenc",
+	"_hsmi",
+}
+
+// URLPolicy controls how URLs are canonicalised before they become pages
+type URLPolicy struct {
+	TrailingSlash  string   `json:"trailing_slash,omitempty"` // TrailingSlashKeep (the default), TrailingSlashAdd or TrailingSlashRemove
+	TrackingParams []string `json:"tracking_params"`          // Query parameters removed, nil uses DefaultTrackingParams
+}
+
+// ValidTrailingSlash reports whether a trailing slash policy is known. Empty keeps paths as found.
+func ValidTrailingSlash(policy string) bool {
+	switch policy {
+	case "", TrailingSlashKeep, TrailingSlashAdd, TrailingSlashRemove:
+		return true
+	}
+	return false
+}
+
+// Canonicalize returns the canonical form of an absolute http(s) URL. The
+// scheme and host are lowercased, default ports, the fragment and tracking
+// parameters are removed, dot segments are collapsed, the remaining query
+// parameters are sorted by name and the trailing slash policy is applied.
+func (p URLPolicy) Canonicalize(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("not an absolute http(s) URL: %s", rawURL)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6 literal
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	u.User = nil
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	escapedPath := removeDotSegments(u.EscapedPath())
+	switch p.TrailingSlash {
+	case TrailingSlashAdd:
+		if !strings.HasSuffix(escapedPath, "/") && !strings.Contains(path.Base(escapedPath), ".") {
+			escapedPath += "/"
+		}
+	case TrailingSlashRemove:
+		if len(escapedPath) > 1 {
+			escapedPath = strings.TrimRight(escapedPath, "/")
+		}
+	}
+	if escapedPath == "" {
+		escapedPath = "/"
+	}
+	if u.Path, err = url.PathUnescape(escapedPath); err != nil {
+		return "", err
+	}
+	u.RawPath = escapedPath
+
+	u.RawQuery = p.canonicalQuery(u.RawQuery)
+	u.ForceQuery = false
+
+	return u.String(), nil
+}
+
+// CanonicalizeURLs canonicalises each URL and drops the duplicates that leaves,
+// keeping the first occurrence. URLs that can't be canonicalised are kept as they are.
+func (p URLPolicy) CanonicalizeURLs(urls []string) []string {
+	seen := make(map[string]bool, len(urls))
+	canonical := make([]string, 0, len(urls))
+	for _, rawURL := range urls {
+		c, err := p.Canonicalize(rawURL)
+		if err != nil {
+			log.Debug().Err(err).Str("url", rawURL).Msg("Failed to canonicalise URL")
+			c = rawURL
+		}
+		if !seen[c] {
+			seen[c] = true
+			canonical = append(canonical, c)
+		}
+	}
+	return canonical
+}
+
+// CanonicalizeEntries canonicalises the location and extension URLs of sitemap
+// entries, keeping the first entry for locations that become duplicates
+func (p URLPolicy) CanonicalizeEntries(entries []SitemapEntry) []SitemapEntry {
+	seen := make(map[string]bool, len(entries))
+	canonical := make([]SitemapEntry, 0, len(entries))
+	for _, entry := range entries {
+		if loc, err := p.Canonicalize(entry.Loc); err == nil {
+			entry.Loc = loc
+		}
+		if seen[entry.Loc] {
+			continue
+		}
+		seen[entry.Loc] = true
+		if len(entry.Alternates) > 0 {
+			entry.Alternates = p.CanonicalizeURLs(entry.Alternates)
+		}
+		if len(entry.Images) > 0 {
+			entry.Images = p.CanonicalizeURLs(entry.Images)
+		}
+		if len(entry.Videos) > 0 {
+			entry.Videos = p.CanonicalizeURLs(entry.Videos)
+		}
+		canonical = append(canonical, entry)
+	}
+	return canonical
+}
+
+// canonicalQuery removes tracking parameters and sorts the rest by name,
+// keeping the order of repeated parameters and their original encoding
+func (p URLPolicy) canonicalQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	tracking := p.TrackingParams
+	if tracking == nil {
+		tracking = DefaultTrackingParams
+	}
+
+	type param struct{ name, pair string }
+	var params []param
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if isTrackingParam(name, tracking) {
+			continue
+		}
+		params = append(params, param{name, pair})
+	}
+	sort.SliceStable(params, func(i, j int) bool { return params[i].name < params[j].name })
+
+	pairs := make([]string, len(params))
+	for i, p := range params {
+		pairs[i] = p.pair
+	}
+	return strings.Join(pairs, "&")
+}
+
+// isTrackingParam reports whether a query parameter matches one of the tracking patterns
+func isTrackingParam(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// removeDotSegments collapses "." and ".." segments in an absolute path as
+// RFC 3986 section 5.2.4 describes, keeping a trailing slash
+func removeDotSegments(p string) string {
+	if !strings.Contains(p, ".") {
+		return p
+	}
+	segments := strings.Split(p, "/")
+	out := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, segment)
+		}
+	}
+	result := strings.Join(out, "/")
+	if !strings.HasPrefix(result, "/") {
+		result = "/" + result
+	}
+	return result
+}
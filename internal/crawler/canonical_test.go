@@ -0,0 +1,93 @@
+package crawler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy URLPolicy
+		in     string
+		want   string
+	}{
+		{"host and scheme lowercased", URLPolicy{}, "HTTPS://Example.COM/About", "https://example.com/About"},
+		{"fragment stripped", URLPolicy{}, "https://example.com/about#team", "https://example.com/about"},
+		{"default https port removed", URLPolicy{}, "https://example.com:443/a", "https://example.com/a"},
+		{"default http port removed", URLPolicy{}, "http://example.com:80/a", "http://example.com/a"},
+		{"other port kept", URLPolicy{}, "https://example.com:8443/a", "https://example.com:8443/a"},
+		{"empty path becomes root", URLPolicy{}, "https://example.com", "https://example.com/"},
+		{"dot segments collapsed", URLPolicy{}, "https://example.com/a/./b/../c", "https://example.com/a/c"},
+		{"dot segments above root", URLPolicy{}, "https://example.com/../../a", "https://example.com/a"},
+		{"trailing dot segment keeps slash", URLPolicy{}, "https://example.com/a/b/..", "https://example.com/a/"},
+		{"default tracking params removed", URLPolicy{}, "https://example.com/a?utm_source=x&UTM_Medium=y&gclid=1&id=2", "https://example.com/a?id=2"},
+		{"query sorted by name", URLPolicy{}, "https://example.com/a?b=2&a=1&b=1", "https://example.com/a?a=1&b=2&b=1"},
+		{"empty query dropped", URLPolicy{}, "https://example.com/a?utm_source=x", "https://example.com/a"},
+		{"encoding kept", URLPolicy{}, "https://example.com/caf%C3%A9?q=a%20b", "https://example.com/caf%C3%A9?q=a%20b"},
+		{"custom tracking params", URLPolicy{TrackingParams: []string{"ref", "src_*"}}, "https://example.com/?ref=a&src_x=1&utm_source=x", "https://example.com/?utm_source=x"},
+		{"no tracking params", URLPolicy{TrackingParams: []string{}}, "https://example.com/?gclid=1", "https://example.com/?gclid=1"},
+		{"keep trailing slash", URLPolicy{TrailingSlash: TrailingSlashKeep}, "https://example.com/about/", "https://example.com/about/"},
+		{"add trailing slash", URLPolicy{TrailingSlash: TrailingSlashAdd}, "https://example.com/about?x=1", "https://example.com/about/?x=1"},
+		{"add skips files", URLPolicy{TrailingSlash: TrailingSlashAdd}, "https://example.com/docs/guide.pdf", "https://example.com/docs/guide.pdf"},
+		{"remove trailing slash", URLPolicy{TrailingSlash: TrailingSlashRemove}, "https://example.com/about/", "https://example.com/about"},
+		{"remove keeps root", URLPolicy{TrailingSlash: TrailingSlashRemove}, "https://example.com/", "https://example.com/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.policy.Canonicalize(tt.in)
+			if err != nil {
+				t.Fatalf("Canonicalize(%q): %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("Canonicalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			again, _ := tt.policy.Canonicalize(got)
+			if again != got {
+				t.Errorf("Canonicalize is not idempotent: %q became %q", got, again)
+			}
+		})
+	}
+
+	for _, in := range []string{"/relative", "mailto:a@example.com", "ftp://example.com/a"} {
+		if _, err := (URLPolicy{}).Canonicalize(in); err == nil {
+			t.Errorf("Canonicalize(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestCanonicalizeURLs(t *testing.T) {
+	policy := URLPolicy{TrailingSlash: TrailingSlashRemove}
+	got := policy.CanonicalizeURLs([]string{
+		"https://example.com/about",
+		"https://example.com/about/",
+		"https://example.com/about#team",
+		"https://Example.com/about?utm_source=x",
+		"https://example.com/contact",
+		"mailto:a@example.com",
+	})
+	want := []string{
+		"https://example.com/about",
+		"https://example.com/contact",
+		"mailto:a@example.com",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CanonicalizeURLs = %v, want %v", got, want)
+	}
+}
+
+func TestCanonicalizeEntries(t *testing.T) {
+	entries := []SitemapEntry{
+		{Loc: "https://example.com/a/", Priority: 0.8, Images: []string{"https://example.com/img.png#x", "https://example.com/img.png"}},
+		{Loc: "https://example.com/a", Priority: 0.1},
+		{Loc: "https://example.com/b?utm_campaign=x"},
+	}
+	got := URLPolicy{TrailingSlash: TrailingSlashRemove}.CanonicalizeEntries(entries)
+	want := []SitemapEntry{
+		{Loc: "https://example.com/a", Priority: 0.8, Images: []string{"https://example.com/img.png"}},
+		{Loc: "https://example.com/b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CanonicalizeEntries = %+v, want %+v", got, want)
+	}
+}
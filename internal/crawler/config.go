@@ -6,32 +6,70 @@ import (
 
 // Config holds the configuration for a crawler instance
 type Config struct {
-	DefaultTimeout time.Duration // Default timeout for requests
-	MaxConcurrency int           // Maximum number of concurrent requests
-	RateLimit      int           // Maximum requests per second
-	UserAgent      string        // User agent string for requests
-	RetryAttempts  int           // Number of retry attempts for failed requests
-	RetryDelay     time.Duration // Delay between retry attempts
-	SkipCachedURLs bool          // Whether to skip URLs that are already cached (HIT)
-	Port           string        // Server port
-	Env            string        // Environment (development/production)
-	LogLevel       string        // Logging level
-	DatabaseURL    string        // Database connection URL
-	AuthToken      string        // Database authentication token
-	SentryDSN      string        // Sentry DSN for error tracking
-	FindLinks      bool          // Whether to extract links (e.g. PDFs/docs) from pages
+	DefaultTimeout  time.Duration // Default timeout for requests
+	MaxConcurrency  int           // Maximum number of concurrent requests
+	RateLimit       int           // Maximum requests per second
+	UserAgent       string        // User agent string for requests
+	RetryAttempts   int           // Number of retry attempts for failed requests
+	RetryDelay      time.Duration // Delay between retry attempts
+	MaxRetryDelay   time.Duration // Longest Retry-After delay to honour before giving up
+	SkipCachedURLs  bool          // Whether to skip URLs that are already cached (HIT)
+	Port            string        // Server port
+	Env             string        // Environment (development/production)
+	LogLevel        string        // Logging level
+	DatabaseURL     string        // Database connection URL
+	AuthToken       string        // Database authentication token
+	SentryDSN       string        // Sentry DSN for error tracking
+	FindLinks       bool          // Whether to extract links (e.g. PDFs/docs) from pages
+	VerifyAttempts  int           // Default follow-up requests when verifying a cache HIT
+	VerifyDelay     time.Duration // Default delay between cache verification requests
+	CDNDebugHeaders bool          // Whether to send every CDN's debug headers to every host, not only to hosts seen behind that CDN
+	Auth            *RequestAuth  // Credentials sent with every request, including sitemap discovery
+	MaxBodySize     int64         // Maximum body bytes read per response before it is truncated (0 is unlimited)
+	BodyBufferSize  int64         // Body prefix kept in memory for link extraction and content checks
+
+	// Sitemap traversal limits
+	SitemapMaxDepth    int // Levels of nested sitemap indexes followed below the root sitemaps
+	SitemapConcurrency int // Sitemaps fetched at once
+	MaxSitemapURLs     int // Page URLs collected from a job's sitemaps before traversal stops (0 is unlimited)
+
+	// Connection pooling for the crawler's shared transport
+	MaxIdleConns        int           // Maximum idle connections across all hosts
+	MaxIdleConnsPerHost int           // Maximum idle connections kept per host
+	MaxConnsPerHost     int           // Maximum connections per host, including active ones (0 is unlimited)
+	IdleConnTimeout     time.Duration // How long an idle connection is kept open
+	TLSHandshakeTimeout time.Duration // Maximum time to wait for a TLS handshake
+	KeepAlive           time.Duration // TCP keep-alive interval for open connections
+	DisableHTTP2        bool          // Whether to stop negotiating HTTP/2 with servers that support it
 }
 
 // DefaultConfig returns a Config instance with default values
 func DefaultConfig() *Config {
 	return &Config{
-		DefaultTimeout: 30 * time.Second,
-		MaxConcurrency: 50,
-		RateLimit:      100,
-		UserAgent:      "Blue Banded Bee (Cache-warmer)",
-		RetryAttempts:  3,
-		RetryDelay:     500 * time.Millisecond,
-		SkipCachedURLs: false, // Default to crawling all URLs
-		FindLinks:      false,
+		DefaultTimeout:  30 * time.Second,
+		MaxConcurrency:  50,
+		RateLimit:       100,
+		UserAgent:       "Blue Banded Bee (Cache-warmer)",
+		RetryAttempts:   3,
+		RetryDelay:      500 * time.Millisecond,
+		MaxRetryDelay:   30 * time.Second,
+		SkipCachedURLs:  false, // Default to crawling all URLs
+		FindLinks:       false,
+		VerifyAttempts:  3,
+		VerifyDelay:     2 * time.Second,
+		CDNDebugHeaders: false,     // Hosts get the debug headers of the CDN they were seen behind
+		MaxBodySize:     100 << 20, // 100MB
+		BodyBufferSize:  2 << 20,   // 2MB
+
+		SitemapMaxDepth:    5,
+		SitemapConcurrency: 4,
+		MaxSitemapURLs:     100000,
+
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 25,
+		MaxConnsPerHost:     50,
+		IdleConnTimeout:     120 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+		KeepAlive:           30 * time.Second,
 	}
 }
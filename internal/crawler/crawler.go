@@ -3,12 +3,18 @@ package crawler
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/net/html"
@@ -19,9 +25,19 @@ import (
 
 // Crawler represents a URL crawler with configuration and metrics
 type Crawler struct {
-	config *Config
-	colly  *colly.Collector
-	id     string // Add an ID field to identify each crawler instance
+	config    *Config
+	colly     *colly.Collector
+	id        string          // Add an ID field to identify each crawler instance
+	userAgent string          // User-Agent sent with every request unless overridden
+	transport *http.Transport // Shared transport so connections are reused across requests
+	client    *http.Client    // Client using the shared transport and default timeout
+	jar       http.CookieJar  // Cookies for the configured auth, nil when there is none
+
+	edgeMu      sync.Mutex
+	edgeClients map[string]*http.Client // Clients pinned to specific edge IPs
+
+	cdnMu    sync.RWMutex
+	cdnHosts map[string]CacheDetector // CDN each host was last seen behind
 }
 
 // New creates a new Crawler instance with the given configuration and optional ID
@@ -41,12 +57,15 @@ func New(config *Config, id ...string) *Crawler {
 		userAgent = fmt.Sprintf("%s Worker-%s", config.UserAgent, crawlerID)
 	}
 
+	transport := newTransport(config)
+
 	c := colly.NewCollector(
 		colly.UserAgent(userAgent),
 		colly.MaxDepth(1),
 		colly.Async(true),
 		colly.AllowURLRevisit(),
 	)
+	c.WithTransport(transport)
 
 	c.Limit(&colly.LimitRule{
 		DomainGlob:  "*",
@@ -93,16 +112,32 @@ func New(config *Config, id ...string) *Crawler {
 		})
 	}
 
+	jar := NewCookieJar(config.Auth)
+
 	return &Crawler{
-		config: config,
-		colly:  c,
-		id:     crawlerID,
+		config:    config,
+		colly:     c,
+		id:        crawlerID,
+		userAgent: userAgent,
+		transport: transport,
+		client:    &http.Client{Timeout: config.DefaultTimeout, Transport: transport, Jar: jar, CheckRedirect: config.Auth.CheckRedirect},
+		jar:       jar,
+
+		edgeClients: make(map[string]*http.Client),
+		cdnHosts:    make(map[string]CacheDetector),
 	}
 }
 
 // WarmURL performs a crawl of the specified URL and returns the result.
 // It respects context cancellation, enforces timeout, and treats non-2xx statuses as errors.
 func (c *Crawler) WarmURL(ctx context.Context, targetURL string, findLinks bool) (*CrawlResult, error) {
+	return c.WarmURLWithOptions(ctx, targetURL, WarmOptions{FindLinks: findLinks})
+}
+
+// WarmURLWithOptions warms the specified URL using the given options.
+// When VerifyCache is set, follow-up requests are made until the edge reports
+// a cache HIT or the verification attempts are exhausted.
+func (c *Crawler) WarmURLWithOptions(ctx context.Context, targetURL string, opts WarmOptions) (*CrawlResult, error) {
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
@@ -119,29 +154,249 @@ func (c *Crawler) WarmURL(ctx context.Context, targetURL string, findLinks bool)
 		return res, err
 	}
 
+	res, err := c.requestWithRetry(ctx, targetURL, opts)
+	if err != nil {
+		return res, err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res, errors.New(res.Error)
+	}
+
+	res.FirstCacheStatus = res.CacheStatus
+	res.CacheAttempts = 1
+	res.CacheVerified = isCacheHit(res.CacheStatus)
+
+	// Only verify successful responses, errors will never be cached
+	if opts.VerifyCache && !res.CacheVerified && res.Error == "" {
+		c.verifyCache(ctx, res, opts)
+	}
+
+	// Warn about the final cache status once verification has settled
+	c.validateCacheStatus(res)
+
+	return res, nil
+}
+
+// verifyCache re-requests the URL until the edge reports a HIT or the
+// verification attempts are exhausted, updating the result's cache status
+func (c *Crawler) verifyCache(ctx context.Context, res *CrawlResult, opts WarmOptions) {
+	targetURL := res.URL
+
+	attempts := opts.VerifyAttempts
+	if attempts <= 0 {
+		attempts = c.config.VerifyAttempts
+	}
+	delay := opts.VerifyDelay
+	if delay <= 0 {
+		delay = c.config.VerifyDelay
+	}
+
+	// Follow-up requests only need the headers, so their bodies are discarded
+	checkOpts := opts
+	checkOpts.FindLinks = false
+	checkOpts.FindAssets = false
+
+	for i := 0; i < attempts && !res.CacheVerified; i++ {
+		select {
+		case <-ctx.Done():
+			log.Debug().
+				Str("url", targetURL).
+				Int("attempts", res.CacheAttempts).
+				Msg("Cache verification cancelled")
+			return
+		case <-time.After(delay):
+		}
+
+		check, err := c.requestURL(ctx, targetURL, checkOpts)
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("url", targetURL).
+				Int("attempt", res.CacheAttempts+1).
+				Msg("Cache verification request failed")
+			break
+		}
+
+		res.CacheAttempts++
+		res.BytesTransferred += check.BytesTransferred
+		res.CacheStatus = check.CacheStatus
+		res.CacheStatusRaw = check.CacheStatusRaw
+		res.CacheVerified = isCacheHit(check.CacheStatus)
+	}
+
+	log.Debug().
+		Str("url", targetURL).
+		Str("first_cache_status", res.FirstCacheStatus).
+		Str("cache_status", res.CacheStatus).
+		Int("attempts", res.CacheAttempts).
+		Bool("verified", res.CacheVerified).
+		Msg("Cache verification completed")
+}
+
+// isCacheHit reports whether a normalised cache status confirms a HIT
+func isCacheHit(status string) bool {
+	return CacheStatus(status) == CacheStatusHit
+}
+
+// requestWithRetry requests the URL, retrying network errors, 5xx and 429 responses
+// with exponential backoff and jitter. Retry-After is honoured up to MaxRetryDelay.
+func (c *Crawler) requestWithRetry(ctx context.Context, targetURL string, opts WarmOptions) (*CrawlResult, error) {
+	maxAttempts := c.config.RetryAttempts + 1
+	var attemptErrors []string
+	var bytesTransferred int64
+
+	for attempt := 1; ; attempt++ {
+		res, err := c.requestURL(ctx, targetURL, opts)
+		if res == nil {
+			return res, err
+		}
+
+		if res.Error != "" {
+			attemptErrors = append(attemptErrors, res.Error)
+		}
+		res.RetryCount = attempt - 1
+		res.AttemptErrors = attemptErrors
+
+		// Count the bodies of failed attempts towards the bytes transferred
+		bytesTransferred += res.BytesTransferred
+		res.BytesTransferred = bytesTransferred
+
+		if !shouldRetry(err, res.StatusCode) || attempt >= maxAttempts || ctx.Err() != nil {
+			return res, err
+		}
+
+		delay := retryBackoff(c.config.RetryDelay, attempt)
+		if retryAfter, ok := parseRetryAfter(res.retryAfter, time.Now()); ok {
+			if c.config.MaxRetryDelay > 0 && retryAfter > c.config.MaxRetryDelay {
+				log.Warn().
+					Str("url", targetURL).
+					Dur("retry_after", retryAfter).
+					Msg("Retry-After exceeds maximum retry delay, giving up")
+				return res, err
+			}
+			if retryAfter > delay {
+				delay = retryAfter
+			}
+		}
+
+		log.Warn().
+			Str("url", targetURL).
+			Int("attempt", attempt).
+			Int("status", res.StatusCode).
+			Str("error", res.Error).
+			Dur("backoff", delay).
+			Msg("Transient failure warming URL, retrying")
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return res, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryBackoff returns the exponential backoff for an attempt with up to 50% random jitter
+func retryBackoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	backoff := base * time.Duration(1<<uint(attempt-1))
+	jitter := time.Duration(rand.Int63n(int64(backoff)/2 + 1))
+	return backoff + jitter
+}
+
+// parseRetryAfter parses a Retry-After header given either as delay seconds or an HTTP-date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		delay := date.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
+
+// requestURL makes a single GET request for the URL and records the response details
+func (c *Crawler) requestURL(ctx context.Context, targetURL string, opts WarmOptions) (*CrawlResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if opts.Wait != nil {
+		if err := opts.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	start := time.Now()
-	res := &CrawlResult{URL: targetURL, Timestamp: start.Unix()}
+	res := &CrawlResult{URL: targetURL, Timestamp: start.Unix(), Edge: opts.Edge}
 
 	log.Debug().
 		Str("url", targetURL).
-		Bool("find_links", findLinks).
+		Bool("find_links", opts.FindLinks).
+		Str("edge", opts.Edge).
 		Msg("Starting URL warming")
 
+	// Trace the request so slow DNS, connects or TLS can be told apart from a slow origin
+	traceCtx, timer := newRequestTimer(ctx)
+
 	// Single HTTP request for both cache warming and link extraction
-	client := &http.Client{Timeout: c.config.DefaultTimeout}
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	req, err := http.NewRequestWithContext(traceCtx, http.MethodGet, targetURL, nil)
 	if err != nil {
 		res.Error = err.Error()
 		res.ResponseTime = time.Since(start).Milliseconds()
 		return res, err
 	}
 
-	// Set a specific User-Agent to identify our crawler
-	req.Header.Set("User-Agent", "Blue-Banded-Bee-Cache-Warmer/1.0")
+	// Identify our crawler, variant headers below may replace the User-Agent
+	req.Header.Set("User-Agent", c.userAgent)
+	c.prepareCacheRequest(req)
+	auth, jar := opts.Auth, opts.Jar
+	if auth == nil {
+		auth = c.config.Auth
+	}
+	if jar == nil {
+		if opts.Auth == nil {
+			jar = c.jar
+		} else {
+			jar = NewCookieJar(opts.Auth)
+		}
+	}
+	auth.Apply(req)
+	for name, value := range opts.Headers {
+		req.Header.Set(name, value)
+	}
+
+	// The auth's cookies and those the site sets come from the jar, and its
+	// headers are kept off other hosts when redirected
+	edgeHost := opts.EdgeHost
+	if edgeHost == "" {
+		edgeHost = req.URL.Hostname()
+	}
+	client := *c.clientFor(opts.Edge, edgeHost)
+	client.Jar = jar
+	client.CheckRedirect = auth.CheckRedirect
 
 	resp, err := client.Do(req)
 	res.ResponseTime = time.Since(start).Milliseconds()
 	if err != nil {
+		// Keep whichever phases completed, they show where the request stalled
+		timer.apply(res, time.Time{})
 		log.Error().
 			Err(err).
 			Str("url", targetURL).
@@ -153,61 +408,99 @@ func (c *Crawler) WarmURL(ctx context.Context, targetURL string, findLinks bool)
 
 	defer resp.Body.Close()
 
-	bodyBytes, err := io.ReadAll(resp.Body)
+	maxBodySize := opts.MaxBodySize
+	if maxBodySize == 0 {
+		maxBodySize = c.config.MaxBodySize
+	}
+
+	// Only text responses are buffered, and only up to a capped prefix. Bodies in an
+	// encoding we asked for ourselves (e.g. a brotli variant) can't be inspected.
+	contentType := resp.Header.Get("Content-Type")
+	encoded := resp.Header.Get("Content-Encoding") != "" && !resp.Uncompressed
+	var bufferSize int64
+	if (opts.FindLinks || isTextContent(contentType) || (opts.FindAssets && isStylesheet(contentType))) && !encoded {
+		bufferSize = c.config.BodyBufferSize
+	}
+
+	bodyBytes, bytesRead, truncated, err := readBody(resp.Body, maxBodySize, bufferSize)
+	timer.apply(res, time.Now())
+	res.BytesTransferred = bytesRead
+	res.ContentLength = resp.ContentLength
+	res.BodyTruncated = truncated
 	if err != nil {
 		log.Error().
 			Err(err).
 			Str("url", targetURL).
+			Int64("bytes_read", bytesRead).
 			Msg("Failed to read response body")
 		res.Error = fmt.Sprintf("failed to read response body: %s", err.Error())
 		return res, err
 	}
 
+	if truncated {
+		log.Warn().
+			Str("url", targetURL).
+			Int64("max_body_size", maxBodySize).
+			Int64("content_length", resp.ContentLength).
+			Msg("Response body exceeded maximum size, stopped reading")
+	}
+
 	res.StatusCode = resp.StatusCode
+	res.retryAfter = resp.Header.Get("Retry-After")
 
-	// Check for cache status headers from different CDNs
-	// Cloudflare
-	if cacheStatus := resp.Header.Get("CF-Cache-Status"); cacheStatus != "" {
-		res.CacheStatus = cacheStatus
-	}
-	// Fastly
-	if cacheStatus := resp.Header.Get("X-Cache"); cacheStatus != "" && res.CacheStatus == "" {
-		res.CacheStatus = cacheStatus
-	}
-	// Akamai
-	if cacheStatus := resp.Header.Get("X-Cache-Remote"); cacheStatus != "" && res.CacheStatus == "" {
-		res.CacheStatus = cacheStatus
-	}
-	// Vercel
-	if cacheStatus := resp.Header.Get("x-vercel-cache"); cacheStatus != "" && res.CacheStatus == "" {
-		res.CacheStatus = cacheStatus
+	// Normalise the cache status reported by whichever CDN served the response
+	cache := DetectCacheStatus(resp.Header)
+	c.rememberCDN(resp)
+	res.CacheStatus = string(cache.Status)
+	res.CacheStatusRaw = cache.Raw
+	res.CDN = cache.CDN
+
+	// Flag error statuses and 200 responses that look like error pages
+	c.handleResponseType(res, resp.Header, bodyBytes)
+
+	// Relative URLs resolve against the URL that was served, after any redirects
+	documentURL := resp.Request.URL.String()
+
+	// The page's head and headers say what its links resolve against, whether
+	// they may be followed and which URL is canonical
+	linkBase := documentURL
+	if strings.Contains(contentType, "text/html") {
+		head := parseHead(bodyBytes, documentURL)
+		linkBase = head.base
+		res.Canonical = head.canonical
+		res.Nofollow = head.nofollow
 	}
-	// Standard Cache-Status header (newer standardized approach)
-	if cacheStatus := resp.Header.Get("Cache-Status"); cacheStatus != "" && res.CacheStatus == "" {
-		res.CacheStatus = cacheStatus
+	if res.Canonical == "" {
+		res.Canonical = headerCanonical(resp.Header, documentURL)
 	}
-	// Varnish (the presence of X-Varnish indicates it was processed by Varnish)
-	if varnishID := resp.Header.Get("X-Varnish"); varnishID != "" && res.CacheStatus == "" {
-		if strings.Contains(varnishID, " ") {
-			res.CacheStatus = "HIT" // Multiple IDs indicate a cache hit
-		} else {
-			res.CacheStatus = "MISS" // Single ID indicates a cache miss
-		}
+	if headerNofollow(resp.Header) {
+		res.Nofollow = true
 	}
 
-	res.ContentType = resp.Header.Get("Content-Type")
-
-	// Extract links only if requested
-	if findLinks {
-		res.Links = extractLinks(bodyBytes, targetURL)
+	// Extract links only if requested, and only from pages that allow following them
+	if opts.FindLinks && !(opts.RespectNofollow && res.Nofollow) {
+		res.Links = extractLinks(bodyBytes, linkBase, opts.RespectNofollow)
 		log.Debug().
 			Str("url", targetURL).
 			Int("links_found", len(res.Links)).
 			Msg("Link extraction completed")
+	} else if opts.FindLinks {
+		log.Debug().
+			Str("url", targetURL).
+			Msg("Page is marked nofollow, skipping link extraction")
+	}
+
+	// Extract assets only if requested, from pages and the stylesheets they load
+	if opts.FindAssets {
+		switch {
+		case strings.Contains(contentType, "text/html"):
+			res.Assets = extractAssets(bodyBytes, linkBase)
+		case isStylesheet(contentType):
+			res.Assets = extractCSSAssets(bodyBytes, documentURL)
+		}
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		res.Error = fmt.Sprintf("non-success status code: %d", resp.StatusCode)
 		log.Warn().
 			Int("status", resp.StatusCode).
 			Str("url", targetURL).
@@ -225,8 +518,54 @@ func (c *Crawler) WarmURL(ctx context.Context, targetURL string, findLinks bool)
 	return res, nil
 }
 
+// readBody reads a response body without holding more than bufferSize bytes in memory.
+// Up to bufferSize bytes are returned for link extraction and content checks and the
+// rest is streamed to io.Discard. Reading stops after maxBodySize bytes, which are
+// then reported as truncated. A maxBodySize of 0 or less reads the whole body.
+func readBody(body io.Reader, maxBodySize, bufferSize int64) ([]byte, int64, bool, error) {
+	reader := body
+	if maxBodySize > 0 {
+		// Read one byte past the limit so an exactly-sized body is not reported as truncated
+		reader = io.LimitReader(body, maxBodySize+1)
+		if bufferSize > maxBodySize {
+			bufferSize = maxBodySize
+		}
+	}
+
+	var prefix []byte
+	if bufferSize > 0 {
+		var err error
+		prefix, err = io.ReadAll(io.LimitReader(reader, bufferSize))
+		if err != nil {
+			return prefix, int64(len(prefix)), false, err
+		}
+	}
+
+	discarded, err := io.Copy(io.Discard, reader)
+	total := int64(len(prefix)) + discarded
+	if err != nil {
+		return prefix, total, false, err
+	}
+
+	if maxBodySize > 0 && total > maxBodySize {
+		return prefix, maxBodySize, true, nil
+	}
+	return prefix, total, false, nil
+}
+
+// isTextContent reports whether a content type is worth buffering for content checks
+func isTextContent(contentType string) bool {
+	return strings.Contains(contentType, "text/html") ||
+		strings.Contains(contentType, "application/json") ||
+		strings.Contains(contentType, "text/plain")
+}
+
+// isStylesheet reports whether a content type is CSS
+func isStylesheet(contentType string) bool {
+	return strings.Contains(contentType, "text/css")
+}
+
 // Helper function to determine if we should retry based on the error or status code
-// TODO: UPdate WarmUrl to use this and handle reponse type below. Incorporate into WarmUrl or call these functions
 func shouldRetry(err error, statusCode int) bool {
 	// Retry on network errors
 	if err != nil {
@@ -246,162 +585,267 @@ func shouldRetry(err error, statusCode int) bool {
 	return false
 }
 
-// Add this function to crawler.go to improve error handling for different response types
-func (c *Crawler) handleResponseType(result *CrawlResult, response *colly.Response) {
+// handleResponseType inspects the response content for signs that a page is broken.
+// Non-2xx statuses get a descriptive error; 2xx responses whose body looks like an
+// error page (soft 404s, invalid JSON, empty or tiny HTML) get a warning.
+func (c *Crawler) handleResponseType(result *CrawlResult, header http.Header, body []byte) {
 	// Get content type from headers
-	contentType := response.Headers.Get("Content-Type")
+	contentType := header.Get("Content-Type")
 
 	// Set content type for metrics
 	result.ContentType = contentType
 
 	// Check status code
-	if response.StatusCode < 200 || response.StatusCode >= 300 {
+	if result.StatusCode < 200 || result.StatusCode >= 300 {
 		switch {
-		case response.StatusCode == 404:
+		case result.StatusCode == 404:
 			result.Error = "HTTP 404: Page not found"
-		case response.StatusCode == 403:
+		case result.StatusCode == 403:
 			result.Error = "HTTP 403: Access forbidden"
-		case response.StatusCode == 401:
+		case result.StatusCode == 401:
 			result.Error = "HTTP 401: Authentication required"
-		case response.StatusCode == 429:
+		case result.StatusCode == 429:
 			result.Error = "HTTP 429: Too many requests - rate limited"
-		case response.StatusCode >= 500 && response.StatusCode < 600:
-			result.Error = fmt.Sprintf("HTTP %d: Server error", response.StatusCode)
+		case result.StatusCode >= 500 && result.StatusCode < 600:
+			result.Error = fmt.Sprintf("HTTP %d: Server error", result.StatusCode)
 		default:
-			result.Error = fmt.Sprintf("HTTP %d: Non-successful status code", response.StatusCode)
+			result.Error = fmt.Sprintf("HTTP %d: Non-successful status code", result.StatusCode)
 		}
 		return
 	}
 
+	// Check for very small response sizes that might indicate an error.
+	// Non-text bodies are streamed rather than buffered, so count the bytes read.
+	if result.BytesTransferred == 0 && len(body) == 0 {
+		setWarning(result, WarningEmptyBody, "Warning: Empty response body")
+		return
+	}
+
+	// Nothing more to check if the body was streamed rather than buffered
+	if len(body) == 0 {
+		return
+	}
+
 	// For 200-level responses, check for specific content types
 	switch {
 	case strings.Contains(contentType, "text/html"):
-		// HTML content - check for specific patterns
-		if len(response.Body) < 100 {
+		// Check the page title for common error patterns
+		title := strings.ToLower(htmlTitle(body))
+		if strings.HasPrefix(title, "404") ||
+			strings.Contains(title, "not found") ||
+			strings.Contains(title, "page doesn't exist") {
+			setWarning(result, WarningSoft404, "Warning: Page content suggests a 404 despite 200 status code")
+		} else if len(body) < 100 {
 			// Very small HTML response might indicate an error page
-			result.Warning = "Warning: Unusually small HTML response"
-		}
-
-		// Check for common error patterns in the body
-		bodyStr := string(response.Body)
-		if strings.Contains(bodyStr, "<title>404") ||
-			strings.Contains(bodyStr, "not found") ||
-			strings.Contains(bodyStr, "page doesn't exist") {
-			result.Warning = "Warning: Page content suggests a 404 despite 200 status code"
+			setWarning(result, WarningSmallHTML, "Warning: Unusually small HTML response")
 		}
 
 	case strings.Contains(contentType, "application/json"):
-		// JSON content - validate it's proper JSON
-		var jsonObj map[string]interface{}
-		if err := json.Unmarshal(response.Body, &jsonObj); err != nil {
-			result.Warning = "Warning: Invalid JSON response"
+		// JSON content - validate it's proper JSON, unless only a prefix was read
+		if int64(len(body)) < result.BytesTransferred {
+			break
+		}
+		if !json.Valid(body) {
+			setWarning(result, WarningInvalidJSON, "Warning: Invalid JSON response")
+			break
 		}
 
 		// Check for error fields in the JSON
-		if errorMsg, ok := jsonObj["error"].(string); ok {
-			result.Warning = fmt.Sprintf("Warning: JSON contains error field: %s", errorMsg)
+		var jsonObj map[string]interface{}
+		if err := json.Unmarshal(body, &jsonObj); err == nil {
+			if errorMsg, ok := jsonObj["error"].(string); ok {
+				setWarning(result, WarningJSONError, fmt.Sprintf("Warning: JSON contains error field: %s", errorMsg))
+			}
 		}
 
 	case strings.Contains(contentType, "text/plain"):
 		// Plain text - check for obvious error messages
-		bodyStr := string(response.Body)
-		if strings.Contains(strings.ToLower(bodyStr), "error") ||
-			strings.Contains(strings.ToLower(bodyStr), "not found") {
-			result.Warning = "Warning: Text appears to contain error message"
+		bodyStr := strings.ToLower(string(body))
+		if strings.Contains(bodyStr, "error") ||
+			strings.Contains(bodyStr, "not found") {
+			setWarning(result, WarningTextError, "Warning: Text appears to contain error message")
 		}
 	}
-
-	// Check for very small response sizes that might indicate an error
-	if len(response.Body) == 0 {
-		result.Warning = "Warning: Empty response body"
-	}
 }
 
-// Add this function to crawler.go to validate cache status
+// validateCacheStatus warns when the final cache status shows the page is not
+// being served from cache. Content warnings from handleResponseType take precedence.
 func (c *Crawler) validateCacheStatus(result *CrawlResult) {
-	// Don't validate if there was an error
-	if result.Error != "" {
+	// Don't validate if there was an error or the content already looks broken
+	if result.Error != "" || result.Warning != "" {
 		return
 	}
 
 	// Check the cache status
-	switch result.CacheStatus {
-	case "HIT":
+	switch CacheStatus(result.CacheStatus) {
+	case CacheStatusHit, CacheStatusRevalidated:
 		// Successful cache hit
 		log.Debug().
 			Str("url", result.URL).
 			Msg("Cache hit confirmed")
 
-	case "MISS":
+	case CacheStatusMiss:
 		// Cache miss - this might be expected for the first request
 		log.Debug().
 			Str("url", result.URL).
 			Msg("Cache miss detected")
 
-	case "EXPIRED":
+	case CacheStatusStale:
+		// A stale copy was served while the edge refreshed it
+		setWarning(result, WarningCacheStale, "Stale content served - cache is being refreshed")
+
+	case CacheStatusExpired:
 		// The cached resource was expired
-		result.Warning = "Cache expired - resource needed revalidation"
+		setWarning(result, WarningCacheExpired, "Cache expired - resource needed revalidation")
 
-	case "BYPASS":
+	case CacheStatusBypass:
 		// Cache was bypassed
-		result.Warning = "Cache was bypassed - check cache headers"
+		setWarning(result, WarningCacheBypass, "Cache was bypassed - check cache headers")
 
-	case "DYNAMIC":
+	case CacheStatusDynamic:
 		// Content was dynamically generated
-		result.Warning = "Content served dynamically - not cacheable"
-
-	case "":
-		// No cache status header found
-		result.Warning = "No cache status header found - CDN might not be enabled"
+		setWarning(result, WarningCacheDynamic, "Content served dynamically - not cacheable")
 
 	default:
-		// Unknown cache status
-		result.Warning = fmt.Sprintf("Unknown cache status: %s", result.CacheStatus)
+		if result.CacheStatusRaw == "" {
+			// No cache status header found
+			setWarning(result, WarningNoCacheHeader, "No cache status header found - CDN might not be enabled")
+		} else {
+			// Unknown cache status
+			setWarning(result, WarningUnknownCache, fmt.Sprintf("Unknown cache status: %s", result.CacheStatusRaw))
+		}
 	}
 }
 
+// setWarning records a warning and its category on the result
+func setWarning(result *CrawlResult, warningType, message string) {
+	result.WarningType = warningType
+	result.Warning = message
+}
+
+// htmlTitle returns the text of the first <title> element in an HTML document
+func htmlTitle(body []byte) string {
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "title" {
+				if tokenizer.Next() == html.TextToken {
+					return strings.TrimSpace(string(tokenizer.Text()))
+				}
+				return ""
+			}
+		}
+	}
+}
+
+// CheckCacheStatus makes a HEAD request and returns the normalised cache status
 func (c *Crawler) CheckCacheStatus(ctx context.Context, targetURL string) (string, error) {
 	req, err := http.NewRequestWithContext(ctx, "HEAD", targetURL, nil)
 	if err != nil {
 		return "", err
 	}
 
-	req.Header.Set("User-Agent", c.config.UserAgent)
+	req.Header.Set("User-Agent", c.userAgent)
+	c.prepareCacheRequest(req)
+	c.config.Auth.Apply(req)
 
-	client := &http.Client{
-		Timeout: c.config.DefaultTimeout,
-	}
-
-	resp, err := client.Do(req)
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
-	return resp.Header.Get("CF-Cache-Status"), nil
+	c.rememberCDN(resp)
+	return string(DetectCacheStatus(resp.Header).Status), nil
 }
 
-// CreateHTTPClient returns a configured HTTP client
+// prepareCacheRequest adds the debug headers of the CDN the request's host was last
+// seen behind, so a CDN that only reports a cache status when asked does so from
+// the host's second request. Hosts not yet seen get none, unless CDNDebugHeaders
+// sends every CDN's headers to every host.
+func (c *Crawler) prepareCacheRequest(req *http.Request) {
+	if c.config.CDNDebugHeaders {
+		PrepareCacheRequest(req)
+		return
+	}
+	c.cdnMu.RLock()
+	detector := c.cdnHosts[strings.ToLower(req.URL.Host)]
+	c.cdnMu.RUnlock()
+	if detector != nil {
+		detector.PrepareRequest(req)
+	}
+}
+
+// rememberCDN records the CDN that served a response for later requests to its host
+func (c *Crawler) rememberCDN(resp *http.Response) {
+	detector := IdentifyCDN(resp.Header)
+	if detector == nil {
+		return
+	}
+	c.cdnMu.Lock()
+	c.cdnHosts[strings.ToLower(resp.Request.URL.Host)] = detector
+	c.cdnMu.Unlock()
+}
+
+// CreateHTTPClient returns an HTTP client that shares the crawler's transport.
+// A zero timeout uses the configured default.
 func (c *Crawler) CreateHTTPClient(timeout time.Duration) *http.Client {
 	if timeout == 0 {
 		timeout = c.config.DefaultTimeout
 	}
 
 	return &http.Client{
-		Timeout: timeout,
-		Transport: &http.Transport{
-			MaxIdleConnsPerHost: 25,
-			MaxConnsPerHost:     50,
-			IdleConnTimeout:     120 * time.Second,
-			TLSHandshakeTimeout: 10 * time.Second,
-			DisableCompression:  true,
-			ForceAttemptHTTP2:   true,
-		},
+		Timeout:       timeout,
+		Transport:     c.transport,
+		Jar:           c.jar,
+		CheckRedirect: c.config.Auth.CheckRedirect,
 	}
 }
 
-// extractLinks parses HTML body and returns all anchor hrefs as absolute URLs
-func extractLinks(body []byte, base string) []string {
+// CloseIdleConnections closes any idle connections held by the crawler's transports
+func (c *Crawler) CloseIdleConnections() {
+	c.transport.CloseIdleConnections()
+
+	c.edgeMu.Lock()
+	defer c.edgeMu.Unlock()
+	for _, client := range c.edgeClients {
+		client.CloseIdleConnections()
+	}
+}
+
+// newTransport builds the long-lived transport shared by every request a crawler makes
+func newTransport(config *Config) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   config.DefaultTimeout,
+		KeepAlive: config.KeepAlive,
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          config.MaxIdleConns,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       config.MaxConnsPerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+		ForceAttemptHTTP2:     !config.DisableHTTP2,
+	}
+
+	if config.DisableHTTP2 {
+		// A non-nil, empty map stops the transport from upgrading to HTTP/2
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	return transport
+}
+
+// extractLinks parses HTML body and returns all anchor hrefs as absolute URLs,
+// resolved against base, leaving out rel="nofollow" anchors when skipNofollow is set
+func extractLinks(body []byte, base string, skipNofollow bool) []string {
 	var links []string
 	baseURL, err := url.Parse(base)
 	if err != nil {
@@ -421,7 +865,7 @@ func extractLinks(body []byte, base string) []string {
 	}
 	var f func(*html.Node)
 	f = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "a" {
+		if n.Type == html.ElementNode && n.Data == "a" && !(skipNofollow && hasRel(attrValue(n, "rel"), "nofollow")) {
 			for _, attr := range n.Attr {
 				if attr.Key == "href" {
 					u, err := url.Parse(attr.Val)
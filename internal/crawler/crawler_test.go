@@ -2,8 +2,11 @@ package crawler
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -64,7 +67,10 @@ func TestWarmURLWithDifferentStatuses(t *testing.T) {
 			}))
 			defer ts.Close()
 
-			crawler := New(nil)
+			// Server errors are retried, so keep the backoff short
+			config := DefaultConfig()
+			config.RetryDelay = time.Millisecond
+			crawler := New(config)
 			result, err := crawler.WarmURL(context.Background(), ts.URL, false)
 
 			if (err != nil) != tt.wantError {
@@ -115,3 +121,475 @@ func TestWarmURLWithTimeout(t *testing.T) {
 		t.Error("Expected timeout error, got nil")
 	}
 }
+
+func TestWarmURLVerifyCache(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Report MISS until the third request
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.Header().Set("CF-Cache-Status", "MISS")
+		} else {
+			w.Header().Set("CF-Cache-Status", "HIT")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	crawler := New(nil)
+	result, err := crawler.WarmURLWithOptions(context.Background(), ts.URL, WarmOptions{
+		VerifyCache:    true,
+		VerifyAttempts: 5,
+		VerifyDelay:    time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if result.FirstCacheStatus != "MISS" {
+		t.Errorf("Expected first cache status MISS, got %s", result.FirstCacheStatus)
+	}
+	if result.CacheStatus != "HIT" {
+		t.Errorf("Expected final cache status HIT, got %s", result.CacheStatus)
+	}
+	if result.CacheAttempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", result.CacheAttempts)
+	}
+	if !result.CacheVerified {
+		t.Error("Expected cache to be verified")
+	}
+}
+
+func TestWarmURLWaitsBeforeEachRequest(t *testing.T) {
+	var requests, waits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.Header().Set("CF-Cache-Status", "MISS")
+		} else {
+			w.Header().Set("CF-Cache-Status", "HIT")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	crawler := New(nil)
+	_, err := crawler.WarmURLWithOptions(context.Background(), ts.URL, WarmOptions{
+		VerifyCache:    true,
+		VerifyAttempts: 5,
+		VerifyDelay:    time.Millisecond,
+		Wait: func(ctx context.Context) error {
+			atomic.AddInt32(&waits, 1)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if requests != 3 || waits != 3 {
+		t.Errorf("Expected a wait before each of 3 requests, got %d waits for %d requests", waits, requests)
+	}
+
+	// A failed wait makes no request
+	_, err = crawler.WarmURLWithOptions(context.Background(), ts.URL, WarmOptions{
+		Wait: func(ctx context.Context) error { return context.Canceled },
+	})
+	if err == nil {
+		t.Error("Expected the wait's error")
+	}
+	if requests != 3 {
+		t.Errorf("Expected no request after a failed wait, got %d requests", requests)
+	}
+}
+
+func TestWarmURLVerifyCacheGivesUp(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Served-By", "cache-syd10131-SYD, cache-akl10321-AKL")
+		w.Header().Set("X-Cache", "HIT, MISS")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	crawler := New(nil)
+	result, err := crawler.WarmURLWithOptions(context.Background(), ts.URL, WarmOptions{
+		VerifyCache:    true,
+		VerifyAttempts: 2,
+		VerifyDelay:    time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if result.CacheAttempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", result.CacheAttempts)
+	}
+	if result.CacheVerified {
+		t.Error("Expected cache not to be verified")
+	}
+}
+
+func TestWarmURLRetriesTransientErrors(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail twice with 503 before succeeding
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	config := DefaultConfig()
+	config.RetryDelay = time.Millisecond
+	crawler := New(config)
+
+	result, err := crawler.WarmURL(context.Background(), ts.URL, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if result.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", result.StatusCode)
+	}
+	if result.RetryCount != 2 {
+		t.Errorf("Expected 2 retries, got %d", result.RetryCount)
+	}
+	if len(result.AttemptErrors) != 2 {
+		t.Errorf("Expected 2 attempt errors, got %v", result.AttemptErrors)
+	}
+}
+
+func TestWarmURLDoesNotRetryClientErrors(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	config := DefaultConfig()
+	config.RetryDelay = time.Millisecond
+	crawler := New(config)
+
+	if _, err := crawler.WarmURL(context.Background(), ts.URL, false); err == nil {
+		t.Error("Expected error for 404 status")
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("Expected 1 request, got %d", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"seconds", "120", 2 * time.Minute, true},
+		{"http date", "Mon, 01 Jan 2024 12:00:30 GMT", 30 * time.Second, true},
+		{"past date", "Mon, 01 Jan 2024 11:00:00 GMT", 0, true},
+		{"empty", "", 0, false},
+		{"invalid", "soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestHandleResponseType(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantType    string
+	}{
+		{"healthy html", "text/html", "<html><head><title>Home</title></head><body>" + strings.Repeat("content ", 20) + "</body></html>", ""},
+		{"soft 404", "text/html; charset=utf-8", "<html><head><title>Page Not Found | Example</title></head><body>" + strings.Repeat("x", 200) + "</body></html>", WarningSoft404},
+		{"tiny html", "text/html", "<html></html>", WarningSmallHTML},
+		{"empty body", "text/html", "", WarningEmptyBody},
+		{"invalid json", "application/json", "{not json", WarningInvalidJSON},
+		{"json array", "application/json", "[1, 2, 3]", ""},
+		{"json error field", "application/json", `{"error": "missing"}`, WarningJSONError},
+		{"text error", "text/plain", "Internal error occurred", WarningTextError},
+	}
+
+	crawler := New(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &CrawlResult{StatusCode: http.StatusOK}
+			header := http.Header{}
+			header.Set("Content-Type", tt.contentType)
+
+			crawler.handleResponseType(result, header, []byte(tt.body))
+			if result.WarningType != tt.wantType {
+				t.Errorf("Expected warning type %q, got %q (%s)", tt.wantType, result.WarningType, result.Warning)
+			}
+		})
+	}
+}
+
+func TestWarmURLCacheWarning(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("CF-Cache-Status", "BYPASS")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Hello, World!"))
+	}))
+	defer ts.Close()
+
+	crawler := New(nil)
+	result, err := crawler.WarmURL(context.Background(), ts.URL, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if result.WarningType != WarningCacheBypass {
+		t.Errorf("Expected warning type %s, got %q", WarningCacheBypass, result.WarningType)
+	}
+}
+
+// benchmarkWarmURL warms a local TLS server from parallel workers and reports
+// how many TLS connections were opened per request
+func benchmarkWarmURL(b *testing.B, sharedTransport bool) {
+	var connections int64
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("CF-Cache-Status", "HIT")
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>Bench</title></head><body>" + strings.Repeat("content ", 50) + "</body></html>"))
+	}))
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&connections, 1)
+		}
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	tlsConfig := ts.Client().Transport.(*http.Transport).TLSClientConfig
+	newCrawler := func() *Crawler {
+		c := New(nil)
+		c.transport.TLSClientConfig = tlsConfig.Clone()
+		return c
+	}
+	shared := newCrawler()
+	defer shared.CloseIdleConnections()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c := shared
+			if !sharedTransport {
+				// Mimic the old behaviour of a fresh client for every request
+				c = newCrawler()
+			}
+			if _, err := c.WarmURL(context.Background(), ts.URL, false); err != nil {
+				b.Error(err)
+			}
+			if !sharedTransport {
+				c.CloseIdleConnections()
+			}
+		}
+	})
+	b.StopTimer()
+
+	b.ReportMetric(float64(atomic.LoadInt64(&connections))/float64(b.N), "conns/op")
+}
+
+func BenchmarkWarmURLSharedTransport(b *testing.B) {
+	benchmarkWarmURL(b, true)
+}
+
+func BenchmarkWarmURLTransportPerRequest(b *testing.B) {
+	benchmarkWarmURL(b, false)
+}
+
+func TestReadBody(t *testing.T) {
+	tests := []struct {
+		name          string
+		size          int
+		maxBodySize   int64
+		bufferSize    int64
+		wantBuffered  int
+		wantRead      int64
+		wantTruncated bool
+	}{
+		{"fits in buffer", 100, 1000, 500, 100, 100, false},
+		{"prefix only", 1000, 0, 100, 100, 1000, false},
+		{"discarded", 1000, 0, 0, 0, 1000, false},
+		{"exactly max", 1000, 1000, 100, 100, 1000, false},
+		{"truncated", 5000, 1000, 100, 100, 1000, true},
+		{"buffer capped at max", 5000, 50, 100, 50, 50, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := strings.NewReader(strings.Repeat("x", tt.size))
+			prefix, read, truncated, err := readBody(body, tt.maxBodySize, tt.bufferSize)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(prefix) != tt.wantBuffered {
+				t.Errorf("Expected %d buffered bytes, got %d", tt.wantBuffered, len(prefix))
+			}
+			if read != tt.wantRead {
+				t.Errorf("Expected %d bytes read, got %d", tt.wantRead, read)
+			}
+			if truncated != tt.wantTruncated {
+				t.Errorf("Expected truncated %v, got %v", tt.wantTruncated, truncated)
+			}
+		})
+	}
+}
+
+func TestWarmURLMaxBodySize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Header().Set("Content-Length", "1048576")
+		w.Write(make([]byte, 1<<20))
+	}))
+	defer ts.Close()
+
+	crawler := New(nil)
+	result, err := crawler.WarmURLWithOptions(context.Background(), ts.URL, WarmOptions{MaxBodySize: 4096})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !result.BodyTruncated {
+		t.Error("Expected body to be truncated")
+	}
+	if result.BytesTransferred != 4096 {
+		t.Errorf("Expected 4096 bytes transferred, got %d", result.BytesTransferred)
+	}
+	if result.ContentLength != 1<<20 {
+		t.Errorf("Expected content length %d, got %d", 1<<20, result.ContentLength)
+	}
+	if result.WarningType == WarningEmptyBody {
+		t.Error("Expected streamed body not to be reported as empty")
+	}
+}
+
+func TestWarmURLTimings(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Hello, World!"))
+	}))
+	defer ts.Close()
+
+	crawler := New(nil)
+	crawler.transport.TLSClientConfig = ts.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+
+	result, err := crawler.WarmURL(context.Background(), ts.URL, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.ConnReused {
+		t.Error("Expected first request to open a new connection")
+	}
+	if result.TTFB < 20 {
+		t.Errorf("Expected TTFB of at least 20ms, got %d", result.TTFB)
+	}
+
+	// A second request should reuse the pooled connection
+	result, err = crawler.WarmURL(context.Background(), ts.URL, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !result.ConnReused {
+		t.Error("Expected second request to reuse the connection")
+	}
+	if result.TLSHandshakeTime != 0 || result.TCPConnectTime != 0 {
+		t.Errorf("Expected no connection setup on reuse, got connect %dms and TLS %dms", result.TCPConnectTime, result.TLSHandshakeTime)
+	}
+}
+
+func TestWarmURLHeaders(t *testing.T) {
+	var userAgent, language atomic.Value
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent.Store(r.Header.Get("User-Agent"))
+		language.Store(r.Header.Get("Accept-Language"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	config := DefaultConfig()
+	crawler := New(config)
+
+	if _, err := crawler.WarmURL(context.Background(), ts.URL, false); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := userAgent.Load(); got != config.UserAgent {
+		t.Errorf("Expected configured User-Agent %q, got %q", config.UserAgent, got)
+	}
+
+	_, err := crawler.WarmURLWithOptions(context.Background(), ts.URL, WarmOptions{
+		Headers: map[string]string{"User-Agent": "Mobile", "Accept-Language": "fr"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := userAgent.Load(); got != "Mobile" {
+		t.Errorf("Expected variant User-Agent, got %q", got)
+	}
+	if got := language.Load(); got != "fr" {
+		t.Errorf("Expected variant Accept-Language, got %q", got)
+	}
+}
+
+func TestWarmURLCDNDebugHeaders(t *testing.T) {
+	var pragma, fastlyDebug atomic.Value
+	newServer := func(server string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pragma.Store(r.Header.Get("Pragma"))
+			fastlyDebug.Store(r.Header.Get("Fastly-Debug"))
+			w.Header().Set("Server", server)
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+	akamai := newServer("AkamaiGHost")
+	defer akamai.Close()
+	origin := newServer("nginx")
+	defer origin.Close()
+
+	warm := func(crawler *Crawler, url string) {
+		t.Helper()
+		if _, err := crawler.WarmURL(context.Background(), url, false); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	// A host gets the debug headers of its CDN once a response shows the CDN
+	crawler := New(nil)
+	warm(crawler, akamai.URL)
+	if pragma.Load() != "" || fastlyDebug.Load() != "" {
+		t.Errorf("Expected no debug headers before the CDN is known, got Pragma %q and Fastly-Debug %q", pragma.Load(), fastlyDebug.Load())
+	}
+	warm(crawler, akamai.URL)
+	if !strings.Contains(pragma.Load().(string), "akamai-x-cache-on") || fastlyDebug.Load() != "" {
+		t.Errorf("Expected only Akamai debug headers, got Pragma %q and Fastly-Debug %q", pragma.Load(), fastlyDebug.Load())
+	}
+
+	// Hosts behind no known CDN never get them
+	warm(crawler, origin.URL)
+	warm(crawler, origin.URL)
+	if pragma.Load() != "" || fastlyDebug.Load() != "" {
+		t.Errorf("Expected no debug headers for an origin, got Pragma %q and Fastly-Debug %q", pragma.Load(), fastlyDebug.Load())
+	}
+
+	// Every CDN's headers are sent to every host when enabled
+	config := DefaultConfig()
+	config.CDNDebugHeaders = true
+	warm(New(config), origin.URL)
+	if pragma.Load() == "" || fastlyDebug.Load() != "1" {
+		t.Errorf("Expected debug headers when enabled, got Pragma %q and Fastly-Debug %q", pragma.Load(), fastlyDebug.Load())
+	}
+}
@@ -0,0 +1,197 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// fallbackSitemapPaths are checked in order when robots.txt lists no sitemaps.
+// /sitemap.xml is also the index Shopify serves, /sitemap_index.xml is Yoast's
+// and /wp-sitemap.xml is the one WordPress core generates.
+var fallbackSitemapPaths = []string{
+	"/sitemap.xml",
+	"/sitemap_index.xml",
+	"/sitemap-index.xml",
+	"/wp-sitemap.xml",
+	"/sitemap.txt",
+}
+
+// SiteRedirect is a redirect followed while discovering a site
+type SiteRedirect struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	StatusCode int    `json:"status_code"`
+}
+
+// SiteDiscovery is what sitemap discovery found for a domain
+type SiteDiscovery struct {
+	BaseURL   string         `json:"base_url"` // Scheme and host the site is served from
+	Sitemaps  []string       `json:"sitemaps"`
+	Redirects []SiteRedirect `json:"redirects,omitempty"`
+}
+
+// hostVariants returns the bases a site may be served from, in the order they're tried
+func hostVariants(domain string) []string {
+	return []string{
+		"https://" + domain,
+		"https://www." + domain,
+		"http://" + domain,
+		"http://www." + domain,
+	}
+}
+
+// discoveryClient returns a client with a short timeout that follows redirects
+func (c *Crawler) discoveryClient() *http.Client {
+	return c.CreateHTTPClient(5 * time.Second)
+}
+
+// DiscoverSite finds the host a domain is served from and its sitemaps. Each
+// host variant is tried until one responds, following redirects to the
+// canonical host. Sitemaps listed in robots.txt are preferred, falling back to
+// the paths common CMSs use.
+func (c *Crawler) DiscoverSite(ctx context.Context, domain string) (*SiteDiscovery, error) {
+	normalizedDomain := normalizeDomain(domain)
+	log.Debug().
+		Str("original_domain", domain).
+		Str("normalized_domain", normalizedDomain).
+		Msg("Starting sitemap discovery with normalized domain")
+
+	client := c.discoveryClient()
+	discovery := &SiteDiscovery{}
+
+	// robots.txt is the most authoritative source, and finds the canonical host
+	robots, baseURL, redirects, err := c.probeSite(ctx, client, normalizedDomain)
+	if baseURL == "" {
+		return nil, fmt.Errorf("no host variant of %s responded: %w", normalizedDomain, err)
+	}
+	discovery.BaseURL = baseURL
+	discovery.Redirects = redirects
+	if err != nil {
+		log.Debug().Err(err).Str("base_url", baseURL).Msg("Failed to read robots.txt")
+	}
+	if robots != nil {
+		discovery.Sitemaps = append(discovery.Sitemaps, robots.Sitemaps...)
+	}
+
+	if len(discovery.Sitemaps) > 0 {
+		log.Debug().Strs("sitemaps", discovery.Sitemaps).Msg("Sitemaps found in robots.txt")
+	} else {
+		log.Debug().Msg("No sitemaps found in robots.txt")
+		for _, path := range fallbackSitemapPaths {
+			sitemapURL, redirects, ok := c.checkSitemap(ctx, client, baseURL+path)
+			discovery.Redirects = append(discovery.Redirects, redirects...)
+			if ok {
+				log.Debug().Str("url", sitemapURL).Msg("Found sitemap at common location")
+				discovery.Sitemaps = append(discovery.Sitemaps, sitemapURL)
+				break
+			}
+		}
+	}
+
+	// Deduplicate sitemaps
+	seen := make(map[string]bool)
+	var uniqueSitemaps []string
+	for _, sitemap := range discovery.Sitemaps {
+		if !seen[sitemap] {
+			seen[sitemap] = true
+			uniqueSitemaps = append(uniqueSitemaps, sitemap)
+		}
+	}
+	discovery.Sitemaps = uniqueSitemaps
+
+	log.Debug().
+		Str("domain", domain).
+		Str("base_url", baseURL).
+		Strs("sitemaps", discovery.Sitemaps).
+		Int("redirect_count", len(discovery.Redirects)).
+		Msg("Sitemap discovery completed")
+
+	return discovery, nil
+}
+
+// SiteBaseURL returns the scheme and host a domain is served from, found by
+// requesting robots.txt from each host variant and following its redirects
+func (c *Crawler) SiteBaseURL(ctx context.Context, domain string) (string, error) {
+	_, baseURL, _, err := c.probeSite(ctx, c.discoveryClient(), normalizeDomain(domain))
+	if baseURL == "" {
+		return "", fmt.Errorf("no host variant of %s responded: %w", domain, err)
+	}
+	return baseURL, nil
+}
+
+// probeSite fetches robots.txt from each host variant until one responds,
+// returning the rules, the base URL the site redirected to and the redirects
+// followed. The base URL is empty when no variant responded.
+func (c *Crawler) probeSite(ctx context.Context, client *http.Client, domain string) (*RobotsRules, string, []SiteRedirect, error) {
+	var lastErr error
+	for _, base := range hostVariants(domain) {
+		req, err := http.NewRequestWithContext(ctx, "GET", base+"/robots.txt", nil)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		req.Header.Set("User-Agent", c.config.UserAgent)
+		c.config.Auth.Apply(req)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Debug().Err(err).Str("base_url", base).Msg("Host variant did not respond")
+			lastErr = err
+			continue
+		}
+
+		final := resp.Request.URL
+		baseURL := final.Scheme + "://" + final.Host
+		redirects := redirectChain(resp)
+		rules, err := robotsFromResponse(resp)
+		resp.Body.Close()
+		return rules, baseURL, redirects, err
+	}
+	return nil, "", nil, lastErr
+}
+
+// checkSitemap reports whether a sitemap exists at the URL, returning the URL
+// it was found at after redirects. Servers that reject HEAD are asked with GET.
+func (c *Crawler) checkSitemap(ctx context.Context, client *http.Client, sitemapURL string) (string, []SiteRedirect, bool) {
+	log.Debug().Str("checking_sitemap_url", sitemapURL).Msg("Checking common sitemap location")
+	for _, method := range []string{"HEAD", "GET"} {
+		req, err := http.NewRequestWithContext(ctx, method, sitemapURL, nil)
+		if err != nil {
+			return "", nil, false
+		}
+		req.Header.Set("User-Agent", c.config.UserAgent)
+		c.config.Auth.Apply(req)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Debug().Err(err).Str("url", sitemapURL).Msg("Error fetching sitemap")
+			return "", nil, false
+		}
+		resp.Body.Close()
+		log.Debug().Str("url", sitemapURL).Str("method", method).Int("status", resp.StatusCode).Msg("Sitemap check response")
+
+		if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+			continue
+		}
+		return resp.Request.URL.String(), redirectChain(resp), resp.StatusCode == http.StatusOK
+	}
+	return "", nil, false
+}
+
+// redirectChain returns the redirects followed to reach a response, in order
+func redirectChain(resp *http.Response) []SiteRedirect {
+	var chain []SiteRedirect
+	for req := resp.Request; req.Response != nil; req = req.Response.Request {
+		chain = append(chain, SiteRedirect{
+			From:       req.Response.Request.URL.String(),
+			To:         req.URL.String(),
+			StatusCode: req.Response.StatusCode,
+		})
+	}
+	slices.Reverse(chain)
+	return chain
+}
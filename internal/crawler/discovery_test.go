@@ -0,0 +1,68 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDiscoverSiteFallbacks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			http.Redirect(w, r, "/robots-main.txt", http.StatusMovedPermanently)
+		case "/robots-main.txt":
+			w.Write([]byte("User-agent: *\nDisallow: /admin\n"))
+		case "/wp-sitemap.xml":
+			// Some servers reject HEAD, so discovery retries with GET
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Write([]byte(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"></urlset>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// The test server only speaks plain http, so the https variants fail
+	domain := strings.TrimPrefix(server.URL, "http://")
+	discovery, err := New(DefaultConfig()).DiscoverSite(context.Background(), domain)
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+
+	if discovery.BaseURL != server.URL {
+		t.Errorf("BaseURL = %q, want %q", discovery.BaseURL, server.URL)
+	}
+	if len(discovery.Sitemaps) != 1 || discovery.Sitemaps[0] != server.URL+"/wp-sitemap.xml" {
+		t.Errorf("Sitemaps = %v, want [%s/wp-sitemap.xml]", discovery.Sitemaps, server.URL)
+	}
+	want := SiteRedirect{From: server.URL + "/robots.txt", To: server.URL + "/robots-main.txt", StatusCode: http.StatusMovedPermanently}
+	if len(discovery.Redirects) != 1 || discovery.Redirects[0] != want {
+		t.Errorf("Redirects = %+v, want [%+v]", discovery.Redirects, want)
+	}
+}
+
+func TestDiscoverSiteRobotsSitemaps(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("Sitemap: https://example.com/a.xml\nSitemap: https://example.com/a.xml\nSitemap: https://example.com/b.xml\n"))
+			return
+		}
+		t.Errorf("unexpected request for %s", r.URL.Path)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	sitemaps, err := New(DefaultConfig()).DiscoverSitemaps(context.Background(), strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if len(sitemaps) != 2 || sitemaps[0] != "https://example.com/a.xml" || sitemaps[1] != "https://example.com/b.xml" {
+		t.Errorf("sitemaps = %v", sitemaps)
+	}
+}
@@ -0,0 +1,140 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// clientFor returns the client used to reach an edge for a host. Requests with no
+// edge use the shared client. Each edge and host gets its own transport so pinned
+// connections are never reused for requests that should resolve normally, or for
+// a different edge.
+func (c *Crawler) clientFor(edge, host string) *http.Client {
+	if edge == "" {
+		return c.client
+	}
+	host = strings.ToLower(host)
+	key := edge + " " + host
+
+	c.edgeMu.Lock()
+	defer c.edgeMu.Unlock()
+
+	if client, ok := c.edgeClients[key]; ok {
+		return client
+	}
+
+	transport := c.transport.Clone()
+	// A proxy would be dialled instead of the edge, so pinned requests go direct
+	proxy := c.transport.Proxy
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if proxy == nil || strings.EqualFold(req.URL.Hostname(), host) {
+			return nil, nil
+		}
+		return proxy(req)
+	}
+	transport.DialContext = pinnedDialContext(&net.Dialer{
+		Timeout:   c.config.DefaultTimeout,
+		KeepAlive: c.config.KeepAlive,
+	}, edge, host)
+
+	client := &http.Client{Timeout: c.config.DefaultTimeout, Transport: transport}
+	c.edgeClients[key] = client
+	return client
+}
+
+// pinnedDialContext dials the edge instead of the address the host resolved to.
+// The request URL is unchanged, so the Host header and TLS SNI still name the site.
+// Other hosts, reached by redirects or for assets on a CDN, are dialled normally.
+// An edge without a port uses the port of the original address.
+func pinnedDialContext(dialer *net.Dialer, edge, host string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		addrHost, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(addrHost, host) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		return dialer.DialContext(ctx, network, edgeAddr(edge, port))
+	}
+}
+
+// edgeAddr returns the address dialled for an edge, using the port given when
+// the edge has none
+func edgeAddr(edge, port string) string {
+	if _, _, err := net.SplitHostPort(edge); err == nil {
+		return edge
+	}
+	// JoinHostPort adds the brackets an IPv6 edge may already have
+	return net.JoinHostPort(strings.Trim(edge, "[]"), port)
+}
+
+// ValidateEdge checks that an edge is an IP address, optionally with a port
+func ValidateEdge(edge string) error {
+	host := edge
+	if h, _, err := net.SplitHostPort(edge); err == nil {
+		host = h
+	}
+	if net.ParseIP(strings.Trim(host, "[]")) == nil {
+		return fmt.Errorf("edge must be an IP address: %s", edge)
+	}
+	return nil
+}
+
+// ResolveEdges looks the host up against each DNS server and returns the unique
+// addresses found. Geo-aware DNS returns different edges to different resolvers,
+// so querying several servers finds edges beyond the nearest one.
+func (c *Crawler) ResolveEdges(ctx context.Context, host string, dnsServers []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var edges []string
+	var errs []string
+
+	for _, server := range dnsServers {
+		server := edgeAddr(server, "53")
+
+		resolver := &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				dialer := net.Dialer{Timeout: 5 * time.Second}
+				return dialer.DialContext(ctx, network, server)
+			},
+		}
+
+		addrs, err := resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("host", host).
+				Str("dns_server", server).
+				Msg("Failed to resolve edges")
+			errs = append(errs, fmt.Sprintf("%s: %s", server, err))
+			continue
+		}
+
+		for _, addr := range addrs {
+			ip := addr.IP.String()
+			if !seen[ip] {
+				seen[ip] = true
+				edges = append(edges, ip)
+			}
+		}
+	}
+
+	if len(edges) == 0 && len(errs) > 0 {
+		return nil, fmt.Errorf("failed to resolve %s: %s", host, strings.Join(errs, "; "))
+	}
+
+	log.Debug().
+		Str("host", host).
+		Strs("edges", edges).
+		Msg("Resolved edges")
+
+	return edges, nil
+}
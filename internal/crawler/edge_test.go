@@ -0,0 +1,101 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWarmURLPinnedToEdges(t *testing.T) {
+	type request struct {
+		edge       string
+		host       string
+		serverName string
+	}
+	requests := make(chan request, 10)
+
+	newEdge := func(name string) *httptest.Server {
+		return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests <- request{edge: name, host: r.Host, serverName: r.TLS.ServerName}
+			w.Header().Set("CF-Cache-Status", "HIT")
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+	edgeA := newEdge("a")
+	defer edgeA.Close()
+	edgeB := newEdge("b")
+	defer edgeB.Close()
+
+	crawler := New(nil)
+	// Both test servers share a certificate that is valid for example.com
+	crawler.transport.TLSClientConfig = edgeA.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	defer crawler.CloseIdleConnections()
+
+	for name, ts := range map[string]*httptest.Server{"a": edgeA, "b": edgeB} {
+		edge := strings.TrimPrefix(ts.URL, "https://")
+		result, err := crawler.WarmURLWithOptions(context.Background(), "https://example.com/page", WarmOptions{Edge: edge})
+		if err != nil {
+			t.Fatalf("Expected no error warming edge %s, got %v", name, err)
+		}
+		if result.Edge != edge {
+			t.Errorf("Expected result edge %s, got %s", edge, result.Edge)
+		}
+
+		got := <-requests
+		if got.edge != name {
+			t.Errorf("Expected request to reach edge %s, reached %s", name, got.edge)
+		}
+		if got.host != "example.com" {
+			t.Errorf("Expected Host example.com, got %s", got.host)
+		}
+		if got.serverName != "example.com" {
+			t.Errorf("Expected SNI example.com, got %s", got.serverName)
+		}
+	}
+}
+
+func TestWarmURLEdgeOnlyPinsHost(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer origin.Close()
+
+	crawler := New(nil)
+	defer crawler.CloseIdleConnections()
+
+	// Nothing listens on the edge, so the request only succeeds if it isn't pinned
+	opts := WarmOptions{Edge: "127.0.0.1:1", EdgeHost: "example.com"}
+	if _, err := crawler.WarmURLWithOptions(context.Background(), origin.URL, opts); err != nil {
+		t.Fatalf("Expected a request to another host to resolve normally, got %v", err)
+	}
+}
+
+func TestEdgeAddr(t *testing.T) {
+	tests := map[string]string{
+		"104.16.1.1":          "104.16.1.1:443",
+		"104.16.1.1:8443":     "104.16.1.1:8443",
+		"2606:4700::1":        "[2606:4700::1]:443",
+		"[2606:4700::1]":      "[2606:4700::1]:443",
+		"[2606:4700::1]:8443": "[2606:4700::1]:8443",
+	}
+	for edge, want := range tests {
+		if got := edgeAddr(edge, "443"); got != want {
+			t.Errorf("edgeAddr(%q) = %q, want %q", edge, got, want)
+		}
+	}
+}
+
+func TestValidateEdge(t *testing.T) {
+	for _, edge := range []string{"104.16.1.1", "104.16.1.1:443", "2606:4700::1", "[2606:4700::1]", "[2606:4700::1]:443"} {
+		if err := ValidateEdge(edge); err != nil {
+			t.Errorf("Expected %s to be valid, got %v", edge, err)
+		}
+	}
+	for _, edge := range []string{"", "example.com", "example.com:443"} {
+		if err := ValidateEdge(edge); err == nil {
+			t.Errorf("Expected %s to be invalid", edge)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package crawler
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// pageHead is what a page's <head> declares about how it should be crawled
+type pageHead struct {
+	base      string // <base href> resolved against the request URL, or the request URL
+	canonical string // <link rel="canonical"> resolved against the base
+	nofollow  bool   // Whether <meta name="robots"> asks for no links to be followed
+}
+
+// parseHead reads the base URL, canonical URL and robots directives from the
+// head of an HTML page. Tokenising stops at <body>, so the page isn't parsed in full.
+func parseHead(body []byte, requestURL string) pageHead {
+	head := pageHead{base: requestURL}
+	baseURL, err := url.Parse(requestURL)
+	if err != nil {
+		return head
+	}
+
+	var canonicalHref string
+	seenBase := false
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		tok := z.Token()
+		if tok.Data == "body" {
+			break
+		}
+		switch tok.Data {
+		case "base":
+			// Only the first <base> with an href counts
+			if href := tokenAttr(tok, "href"); href != "" && !seenBase {
+				seenBase = true
+				if u, err := url.Parse(strings.TrimSpace(href)); err == nil {
+					baseURL = baseURL.ResolveReference(u)
+					head.base = baseURL.String()
+				}
+			}
+		case "link":
+			if canonicalHref == "" && hasRel(tokenAttr(tok, "rel"), "canonical") {
+				canonicalHref = strings.TrimSpace(tokenAttr(tok, "href"))
+			}
+		case "meta":
+			if strings.EqualFold(strings.TrimSpace(tokenAttr(tok, "name")), "robots") &&
+				robotsNofollow(tokenAttr(tok, "content")) {
+				head.nofollow = true
+			}
+		}
+	}
+
+	if canonicalHref != "" {
+		if u, err := url.Parse(canonicalHref); err == nil {
+			head.canonical = baseURL.ResolveReference(u).String()
+		}
+	}
+	return head
+}
+
+// robotsNofollow reports whether a comma-separated list of robots directives,
+// from a meta tag or an X-Robots-Tag header, forbids following links. "none"
+// is shorthand for noindex, nofollow.
+func robotsNofollow(directives string) bool {
+	for _, directive := range strings.Split(directives, ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "nofollow", "none":
+			return true
+		}
+	}
+	return false
+}
+
+// headerNofollow reports whether X-Robots-Tag headers forbid following links.
+// Directives scoped to a named crawler ("googlebot: nofollow") are ignored,
+// as they aren't addressed to us.
+func headerNofollow(header http.Header) bool {
+	for _, value := range header.Values("X-Robots-Tag") {
+		if agent, _, ok := strings.Cut(value, ":"); ok {
+			agent = strings.ToLower(strings.TrimSpace(agent))
+			// "unavailable_after: <date>" is a directive, a lone name before it a crawler
+			if !strings.ContainsAny(agent, ", ") && agent != "unavailable_after" {
+				continue
+			}
+		}
+		if robotsNofollow(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerCanonical returns the canonical URL a Link header declares, resolved
+// against the request URL, or "" when there is none
+func headerCanonical(header http.Header, requestURL string) string {
+	base, err := url.Parse(requestURL)
+	if err != nil {
+		return ""
+	}
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			target = strings.TrimSpace(target)
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				name, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(strings.TrimSpace(name), "rel") && hasRel(strings.Trim(strings.TrimSpace(val), `"`), "canonical") {
+					if u, err := url.Parse(target[1 : len(target)-1]); err == nil {
+						return base.ResolveReference(u).String()
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// hasRel reports whether a space-separated rel attribute contains a link type
+func hasRel(rel, linkType string) bool {
+	for _, t := range strings.Fields(rel) {
+		if strings.EqualFold(t, linkType) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenAttr returns the value of a token's attribute, or "" when it isn't set
+func tokenAttr(tok html.Token, key string) string {
+	for _, attr := range tok.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,149 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const linkPage = `<!DOCTYPE html>
+<html>
+<head>
+	<base href="https://example.com/docs/">
+	<base href="https://ignored.example.com/">
+	<link rel="canonical" href="guide">
+	<meta name="robots" content="index, follow">
+</head>
+<body>
+	<a href="intro">Intro</a>
+	<a href="/about" rel="nofollow noopener">About</a>
+	<a href="https://other.example.com/">Other</a>
+	<link rel="canonical" href="/not-in-head">
+</body>
+</html>`
+
+func TestParseHead(t *testing.T) {
+	head := parseHead([]byte(linkPage), "https://example.com/docs/guide?ref=nav")
+	want := pageHead{base: "https://example.com/docs/", canonical: "https://example.com/docs/guide"}
+	if head != want {
+		t.Errorf("parseHead = %+v, want %+v", head, want)
+	}
+
+	head = parseHead([]byte(`<html><head><META NAME="Robots" CONTENT="noindex,NOFOLLOW"></head></html>`), "https://example.com/a")
+	if !head.nofollow || head.base != "https://example.com/a" || head.canonical != "" {
+		t.Errorf("parseHead with meta robots = %+v", head)
+	}
+}
+
+func TestExtractLinksNofollow(t *testing.T) {
+	base := "https://example.com/docs/"
+	all := extractLinks([]byte(linkPage), base, false)
+	want := []string{"https://example.com/docs/intro", "https://example.com/about", "https://other.example.com/"}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("extractLinks = %v, want %v", all, want)
+	}
+
+	followed := extractLinks([]byte(linkPage), base, true)
+	want = []string{"https://example.com/docs/intro", "https://other.example.com/"}
+	if !reflect.DeepEqual(followed, want) {
+		t.Errorf("extractLinks skipping nofollow = %v, want %v", followed, want)
+	}
+}
+
+func TestHeaderNofollow(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   bool
+	}{
+		{nil, false},
+		{[]string{"noindex"}, false},
+		{[]string{"noindex, nofollow"}, true},
+		{[]string{"none"}, true},
+		{[]string{"googlebot: nofollow"}, false},
+		{[]string{"unavailable_after: 25 Jun 2030 15:00:00 PST"}, false},
+		{[]string{"noarchive", "NoFollow"}, true},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		for _, v := range tt.values {
+			header.Add("X-Robots-Tag", v)
+		}
+		if got := headerNofollow(header); got != tt.want {
+			t.Errorf("headerNofollow(%q) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestHeaderCanonical(t *testing.T) {
+	header := http.Header{}
+	header.Add("Link", `</style.css>; rel=preload; as=style, <https://example.com/report.pdf>; rel="canonical"`)
+	if got := headerCanonical(header, "https://example.com/files/report.pdf?v=2"); got != "https://example.com/report.pdf" {
+		t.Errorf("headerCanonical = %q", got)
+	}
+	if got := headerCanonical(http.Header{}, "https://example.com/"); got != "" {
+		t.Errorf("headerCanonical without Link = %q, want empty", got)
+	}
+}
+
+func TestWarmURLRespectsNofollow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/private" {
+			w.Header().Set("X-Robots-Tag", "nofollow")
+		}
+		w.Write([]byte(`<html><head><link rel="canonical" href="/canonical"></head><body>` +
+			`<a href="/a">A</a><a href="/b" rel="nofollow">B</a>` +
+			`<p>Enough content to pass the small page check for this test page.</p></body></html>`))
+	}))
+	defer server.Close()
+
+	c := New(DefaultConfig())
+	opts := WarmOptions{FindLinks: true, RespectNofollow: true}
+
+	res, err := c.WarmURLWithOptions(context.Background(), server.URL+"/page", opts)
+	if err != nil {
+		t.Fatalf("warm: %v", err)
+	}
+	if want := []string{server.URL + "/a"}; !reflect.DeepEqual(res.Links, want) {
+		t.Errorf("Links = %v, want %v", res.Links, want)
+	}
+	if res.Canonical != server.URL+"/canonical" {
+		t.Errorf("Canonical = %q", res.Canonical)
+	}
+
+	res, err = c.WarmURLWithOptions(context.Background(), server.URL+"/private", opts)
+	if err != nil {
+		t.Fatalf("warm: %v", err)
+	}
+	if !res.Nofollow || len(res.Links) != 0 {
+		t.Errorf("nofollow page Nofollow = %v, Links = %v, want no links", res.Nofollow, res.Links)
+	}
+}
+
+func TestWarmURLResolvesAgainstRedirectTarget(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new/page/", http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><link rel="canonical" href="./"></head><body>` +
+			`<a href="child">Child</a>` +
+			`<p>Enough content to pass the small page check for this test page.</p></body></html>`))
+	}))
+	defer server.Close()
+
+	c := New(DefaultConfig())
+	res, err := c.WarmURLWithOptions(context.Background(), server.URL+"/old", WarmOptions{FindLinks: true})
+	if err != nil {
+		t.Fatalf("warm: %v", err)
+	}
+	if want := []string{server.URL + "/new/page/child"}; !reflect.DeepEqual(res.Links, want) {
+		t.Errorf("Links = %v, want %v", res.Links, want)
+	}
+	if want := server.URL + "/new/page/"; res.Canonical != want {
+		t.Errorf("Canonical = %q, want %q", res.Canonical, want)
+	}
+}
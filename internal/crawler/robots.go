@@ -0,0 +1,232 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxRobotsSize is the most of a robots.txt file that is parsed
+const maxRobotsSize = 500 << 10
+
+// RobotsRules holds the parsed groups of a robots.txt file
+type RobotsRules struct {
+	groups   []*robotsGroup
+	Sitemaps []string // Sitemap URLs listed in the file
+}
+
+// robotsGroup is a set of rules for one or more user agents
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// robotsRule is a single Allow or Disallow line
+type robotsRule struct {
+	pattern string
+	allow   bool
+	re      *regexp.Regexp
+}
+
+// ParseRobots parses a robots.txt file. Consecutive User-agent lines share
+// the rules that follow them, unknown lines are ignored and Sitemap lines are
+// collected wherever they appear.
+func ParseRobots(r io.Reader) *RobotsRules {
+	rules := &RobotsRules{}
+	var current *robotsGroup
+	inRules := false
+
+	scanner := bufio.NewScanner(io.LimitReader(r, maxRobotsSize))
+	scanner.Buffer(make([]byte, 64*1024), maxRobotsSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if current == nil || inRules {
+				current = &robotsGroup{}
+				rules.groups = append(rules.groups, current)
+				inRules = false
+			}
+			current.agents = append(current.agents, productToken(value))
+		case "allow", "disallow":
+			if current == nil {
+				continue
+			}
+			inRules = true
+			// An empty Disallow allows everything and adds no rule
+			if value == "" {
+				continue
+			}
+			rule, err := compileRobotsRule(value, key == "allow")
+			if err != nil {
+				log.Debug().Err(err).Str("pattern", value).Msg("Ignoring invalid robots.txt rule")
+				continue
+			}
+			current.rules = append(current.rules, rule)
+		case "crawl-delay":
+			if current == nil {
+				continue
+			}
+			inRules = true
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		case "sitemap":
+			if _, err := url.Parse(value); err == nil && value != "" {
+				rules.Sitemaps = append(rules.Sitemaps, value)
+			}
+		}
+	}
+
+	return rules
+}
+
+// compileRobotsRule turns a path pattern into a regular expression, where *
+// matches any sequence of characters and a trailing $ anchors the end
+func compileRobotsRule(pattern string, allow bool) (robotsRule, error) {
+	anchored := strings.HasSuffix(pattern, "$")
+	parts := strings.Split(strings.TrimSuffix(pattern, "$"), "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return robotsRule{}, err
+	}
+	return robotsRule{pattern: pattern, allow: allow, re: re}, nil
+}
+
+// productToken returns the name at the start of a user agent, before any
+// version or comment. It is lowercased without the spaces and hyphens that
+// product tokens leave out, so "Blue Banded Bee (Cache-warmer)" and
+// "BlueBandedBee/1.0" are both "bluebandedbee".
+func productToken(agent string) string {
+	if i := strings.IndexAny(agent, "/("); i >= 0 {
+		agent = agent[:i]
+	}
+	return strings.NewReplacer(" ", "", "-", "", "_", "").Replace(strings.ToLower(agent))
+}
+
+// groupsFor returns the groups that apply to a user agent: those naming its
+// product token, or else the * groups. As RFC 9309 requires, a group must name
+// the whole token, so a group for "bee" doesn't apply to Blue Banded Bee.
+func (r *RobotsRules) groupsFor(userAgent string) []*robotsGroup {
+	agent := productToken(userAgent)
+	var matched, wildcard []*robotsGroup
+	for _, group := range r.groups {
+		for _, token := range group.agents {
+			if token == "*" {
+				wildcard = append(wildcard, group)
+				break
+			}
+			if token != "" && token == agent {
+				matched = append(matched, group)
+				break
+			}
+		}
+	}
+	if len(matched) > 0 {
+		return matched
+	}
+	return wildcard
+}
+
+// Allowed reports whether the user agent may fetch the path, which includes
+// any query string. The longest matching rule wins and Allow wins ties.
+func (r *RobotsRules) Allowed(userAgent, path string) bool {
+	if r == nil {
+		return true
+	}
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+
+	allowed := true
+	bestLen := -1
+	for _, group := range r.groupsFor(userAgent) {
+		for _, rule := range group.rules {
+			if !rule.re.MatchString(path) {
+				continue
+			}
+			if len(rule.pattern) > bestLen || (len(rule.pattern) == bestLen && rule.allow) {
+				bestLen = len(rule.pattern)
+				allowed = rule.allow
+			}
+		}
+	}
+	return allowed
+}
+
+// CrawlDelay returns the delay the user agent should leave between requests,
+// or zero when none is set
+func (r *RobotsRules) CrawlDelay(userAgent string) time.Duration {
+	if r == nil {
+		return 0
+	}
+	var delay time.Duration
+	for _, group := range r.groupsFor(userAgent) {
+		delay = max(delay, group.crawlDelay)
+	}
+	return delay
+}
+
+// FetchRobots fetches and parses the robots.txt of a domain from the first
+// host variant that responds. A missing file or other client error allows
+// everything and a server error disallows everything. An error is returned
+// only when no host variant responded.
+func (c *Crawler) FetchRobots(ctx context.Context, domain string) (*RobotsRules, error) {
+	rules, _, _, err := c.probeSite(ctx, c.discoveryClient(), normalizeDomain(domain))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch robots.txt: %w", err)
+	}
+	return rules, nil
+}
+
+// disallowAllRobots returns rules that disallow every path to every agent
+func disallowAllRobots() *RobotsRules {
+	rule, _ := compileRobotsRule("/", false)
+	return &RobotsRules{groups: []*robotsGroup{{agents: []string{"*"}, rules: []robotsRule{rule}}}}
+}
+
+// robotsFromResponse parses a robots.txt response according to its status
+func robotsFromResponse(resp *http.Response) (*RobotsRules, error) {
+	switch {
+	case resp.StatusCode >= 500:
+		// RFC 9309 treats an unavailable robots.txt as a complete disallow
+		log.Warn().Str("url", resp.Request.URL.String()).Int("status", resp.StatusCode).Msg("robots.txt unavailable, disallowing all paths")
+		return disallowAllRobots(), nil
+	case resp.StatusCode >= 400:
+		log.Debug().Str("url", resp.Request.URL.String()).Int("status", resp.StatusCode).Msg("No robots.txt, allowing all paths")
+		return &RobotsRules{}, nil
+	case resp.StatusCode != http.StatusOK:
+		return &RobotsRules{}, nil
+	}
+	return ParseRobots(resp.Body), nil
+}
@@ -0,0 +1,153 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRobots = `# Example robots.txt
+User-agent: *
+Disallow: /admin/
+Disallow: /*.pdf$
+Disallow: /search
+Allow: /search/help
+Allow: /admin/public
+Crawl-delay: 2
+
+User-agent: BlueBandedBee
+User-agent: OtherBot
+Disallow: /private
+Allow: /private/ok$
+Disallow: /*?session=
+Crawl-delay: 0.5
+
+User-agent: Blue
+Disallow: /
+
+Sitemap: https://example.com/sitemap.xml
+sitemap: https://example.com/news-sitemap.xml
+`
+
+func TestRobotsAllowed(t *testing.T) {
+	rules := ParseRobots(strings.NewReader(testRobots))
+
+	tests := []struct {
+		agent string
+		path  string
+		want  bool
+	}{
+		// The * group applies to agents no group names
+		{"SomeBot/1.0", "/", true},
+		{"SomeBot/1.0", "/admin/users", false},
+		{"SomeBot/1.0", "/admin/public/page", true},
+		{"SomeBot/1.0", "/files/report.pdf", false},
+		{"SomeBot/1.0", "/files/report.pdf?download=1", true},
+		{"SomeBot/1.0", "/search?q=x", false},
+		{"SomeBot/1.0", "/search/help", true},
+		{"SomeBot/1.0", "/robots.txt", true},
+
+		// The most specific matching group replaces the * group entirely
+		{"Blue Banded Bee (Cache-warmer)", "/admin/users", true},
+		{"Blue Banded Bee (Cache-warmer)", "/private/page", false},
+		{"Blue Banded Bee (Cache-warmer)", "/private/ok", true},
+		{"Blue Banded Bee (Cache-warmer)", "/private/ok/more", false},
+		{"Blue Banded Bee (Cache-warmer)", "/page?session=abc", false},
+		{"OtherBot", "/private", false},
+		{"Blue/2.0", "/anything", false},
+
+		// A group must name the whole product token, not just part of it
+		{"BlueBot", "/anything", true},
+		{"BlueBandedBeeExtra", "/admin/users", false},
+	}
+	for _, tt := range tests {
+		if got := rules.Allowed(tt.agent, tt.path); got != tt.want {
+			t.Errorf("Allowed(%q, %q) = %v, want %v", tt.agent, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsAllowTieAndEmpty(t *testing.T) {
+	rules := ParseRobots(strings.NewReader("User-agent: *\nDisallow: /page\nAllow: /page\n"))
+	if !rules.Allowed("bot", "/page") {
+		t.Error("Allow should win a tie with Disallow")
+	}
+
+	rules = ParseRobots(strings.NewReader("User-agent: *\nDisallow:\n"))
+	if !rules.Allowed("bot", "/anything") {
+		t.Error("empty Disallow should allow everything")
+	}
+
+	var missing *RobotsRules
+	if !missing.Allowed("bot", "/anything") || missing.CrawlDelay("bot") != 0 {
+		t.Error("nil rules should allow everything without a delay")
+	}
+}
+
+func TestRobotsCrawlDelayAndSitemaps(t *testing.T) {
+	rules := ParseRobots(strings.NewReader(testRobots))
+
+	if got := rules.CrawlDelay("SomeBot"); got != 2*time.Second {
+		t.Errorf("CrawlDelay(SomeBot) = %v, want 2s", got)
+	}
+	if got := rules.CrawlDelay("Blue Banded Bee (Cache-warmer)"); got != 500*time.Millisecond {
+		t.Errorf("CrawlDelay(Blue Banded Bee) = %v, want 500ms", got)
+	}
+
+	want := []string{"https://example.com/sitemap.xml", "https://example.com/news-sitemap.xml"}
+	if len(rules.Sitemaps) != len(want) {
+		t.Fatalf("Sitemaps = %v, want %v", rules.Sitemaps, want)
+	}
+	for i := range want {
+		if rules.Sitemaps[i] != want[i] {
+			t.Errorf("Sitemaps[%d] = %q, want %q", i, rules.Sitemaps[i], want[i])
+		}
+	}
+}
+
+func TestRobotsShortTokens(t *testing.T) {
+	rules := ParseRobots(strings.NewReader("User-agent: bee\nUser-agent: a\nDisallow: /\n\nUser-agent: *\nDisallow: /admin\n"))
+
+	// Tokens found inside the user agent don't select a group
+	for _, agent := range []string{"Blue Banded Bee (Cache-warmer)", "Blue-Banded-Bee/1.0"} {
+		if !rules.Allowed(agent, "/page") {
+			t.Errorf("Allowed(%q, /page) = false, want the * group to apply", agent)
+		}
+		if rules.Allowed(agent, "/admin") {
+			t.Errorf("Allowed(%q, /admin) = true, want the * group to apply", agent)
+		}
+	}
+	if rules.Allowed("Bee/1.0", "/page") {
+		t.Error("Allowed(Bee/1.0, /page) = true, want the bee group to apply")
+	}
+}
+
+func TestFetchRobotsStatuses(t *testing.T) {
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusNotFound, true},
+		{http.StatusServiceUnavailable, false},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte("User-agent: *\nDisallow: /page\n"))
+		}))
+
+		// The test server only speaks plain http, so the https variants fail
+		rules, err := New(nil).FetchRobots(context.Background(), strings.TrimPrefix(server.URL, "http://"))
+		server.Close()
+		if err != nil {
+			t.Fatalf("status %d: %v", tt.status, err)
+		}
+		if got := rules.Allowed("Blue Banded Bee (Cache-warmer)", "/page"); got != tt.want {
+			t.Errorf("status %d: Allowed(/page) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
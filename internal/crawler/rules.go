@@ -0,0 +1,218 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Rule precedences, deciding URLs that match both an include and an exclude rule
+const (
+	RulePrecedenceExclude = "exclude" // Excludes win, and with include rules a URL must match one (the default)
+	RulePrecedenceInclude = "include" // Includes win, carving exceptions out of the exclude rules
+)
+
+// NotIncludedRule is the rule reported for URLs dropped because include rules
+// exist and none matched
+const NotIncludedRule = "(not included)"
+
+// URL rule prefixes. Patterns with neither prefix are path globs when they
+// contain a *, and otherwise match anywhere in the URL.
+const (
+	regexRulePrefix = "re:"
+	queryRulePrefix = "query:"
+)
+
+// urlRule is a compiled include or exclude pattern
+type urlRule struct {
+	pattern string
+	match   func(u *url.URL, rawURL string) bool
+}
+
+// URLRules decides which URLs a job warms from its include and exclude patterns.
+// A pattern is one of:
+//
+//	/blog/**        a path glob, where * matches within a segment and ** across segments
+//	re:^/p/\d+$     a regular expression, anchored to the whole path and query
+//	query:page      a query parameter that's present, or query:page=2 or query:sort=* for its value
+//	/blog/          any other text, matched anywhere in the URL
+type URLRules struct {
+	include    []urlRule
+	exclude    []urlRule
+	precedence string
+}
+
+// CompileURLRules compiles include and exclude patterns with a precedence,
+// returning an error for invalid patterns or an unknown precedence
+func CompileURLRules(include, exclude []string, precedence string) (*URLRules, error) {
+	switch precedence {
+	case "":
+		precedence = RulePrecedenceExclude
+	case RulePrecedenceExclude, RulePrecedenceInclude:
+	default:
+		return nil, fmt.Errorf("unknown rule precedence: %s", precedence)
+	}
+
+	rules := &URLRules{precedence: precedence}
+	for _, pattern := range include {
+		rule, err := compileURLRule(pattern)
+		if err != nil {
+			return nil, err
+		}
+		rules.include = append(rules.include, rule)
+	}
+	for _, pattern := range exclude {
+		rule, err := compileURLRule(pattern)
+		if err != nil {
+			return nil, err
+		}
+		rules.exclude = append(rules.exclude, rule)
+	}
+	return rules, nil
+}
+
+// IsEmpty reports whether the rules keep every URL
+func (r *URLRules) IsEmpty() bool {
+	return r == nil || (len(r.include) == 0 && len(r.exclude) == 0)
+}
+
+// Match reports whether a URL is kept and, when it isn't, the pattern that excluded it
+func (r *URLRules) Match(rawURL string) (bool, string) {
+	if r.IsEmpty() {
+		return true, ""
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		u = &url.URL{Path: rawURL}
+	}
+
+	included := len(r.include) == 0
+	for _, rule := range r.include {
+		if rule.match(u, rawURL) {
+			included = true
+			break
+		}
+	}
+	if included && r.precedence == RulePrecedenceInclude && len(r.include) > 0 {
+		return true, ""
+	}
+	for _, rule := range r.exclude {
+		if rule.match(u, rawURL) {
+			return false, rule.pattern
+		}
+	}
+	if !included && r.precedence == RulePrecedenceExclude {
+		return false, NotIncludedRule
+	}
+	return true, ""
+}
+
+// Filter returns the URLs the rules keep, and the URLs each rule excluded
+func (r *URLRules) Filter(urls []string) ([]string, map[string][]string) {
+	if r.IsEmpty() {
+		return urls, nil
+	}
+	kept := make([]string, 0, len(urls))
+	excluded := make(map[string][]string)
+	for _, u := range urls {
+		if ok, rule := r.Match(u); ok {
+			kept = append(kept, u)
+		} else {
+			excluded[rule] = append(excluded[rule], u)
+		}
+	}
+	return kept, excluded
+}
+
+// compileURLRule compiles a single include or exclude pattern
+func compileURLRule(pattern string) (urlRule, error) {
+	rule := urlRule{pattern: pattern}
+	switch {
+	case strings.HasPrefix(pattern, regexRulePrefix):
+		re, err := regexp.Compile(`^(?:` + strings.TrimPrefix(pattern, regexRulePrefix) + `)$`)
+		if err != nil {
+			return rule, fmt.Errorf("invalid rule %q: %w", pattern, err)
+		}
+		rule.match = func(u *url.URL, _ string) bool {
+			target := u.EscapedPath()
+			if u.RawQuery != "" {
+				target += "?" + u.RawQuery
+			}
+			return re.MatchString(target)
+		}
+
+	case strings.HasPrefix(pattern, queryRulePrefix):
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(pattern, queryRulePrefix), "=")
+		if name == "" {
+			return rule, fmt.Errorf("invalid rule %q: missing query parameter name", pattern)
+		}
+		valueRe := regexp.MustCompile(`^` + strings.ReplaceAll(regexp.QuoteMeta(value), `\*`, `.*`) + `$`)
+		rule.match = func(u *url.URL, _ string) bool {
+			values, ok := u.Query()[name]
+			if !ok || !hasValue {
+				return ok
+			}
+			for _, v := range values {
+				if valueRe.MatchString(v) {
+					return true
+				}
+			}
+			return false
+		}
+
+	case strings.Contains(pattern, "*"):
+		re := regexp.MustCompile(globPattern(pattern))
+		rule.match = func(u *url.URL, _ string) bool {
+			return re.MatchString(u.Path)
+		}
+
+	default:
+		rule.match = func(_ *url.URL, rawURL string) bool {
+			return strings.Contains(rawURL, pattern)
+		}
+	}
+	return rule, nil
+}
+
+// globPattern converts a path glob to an anchored regular expression. A
+// trailing /** also matches the directory itself, and **/ matches no directories.
+func globPattern(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); {
+		switch {
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("(?:/.*)?")
+			i += 3
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 3
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i += 2
+		case glob[i] == '*':
+			b.WriteString("[^/]*")
+			i++
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			i++
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// FilterURLs keeps the URLs matching the include patterns, if any, and none of
+// the exclude patterns. Invalid patterns leave the URLs unfiltered.
+func (c *Crawler) FilterURLs(urls []string, includePaths, excludePaths []string) []string {
+	rules, err := CompileURLRules(includePaths, excludePaths, "")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to compile URL rules, not filtering")
+		return urls
+	}
+	kept, _ := rules.Filter(urls)
+	return kept
+}
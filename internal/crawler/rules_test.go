@@ -0,0 +1,89 @@
+package crawler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestURLRulesMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		include    []string
+		exclude    []string
+		precedence string
+		url        string
+		wantKeep   bool
+		wantRule   string
+	}{
+		{"no rules", nil, nil, "", "https://example.com/a", true, ""},
+		{"recursive glob", []string{"/blog/**"}, nil, "", "https://example.com/blog/2024/post", true, ""},
+		{"recursive glob matches directory", []string{"/blog/**"}, nil, "", "https://example.com/blog", true, ""},
+		{"recursive glob misses sibling", []string{"/blog/**"}, nil, "", "https://example.com/blogroll", false, NotIncludedRule},
+		{"single segment glob", nil, []string{"/tag/*"}, "", "https://example.com/tag/go", false, "/tag/*"},
+		{"single segment glob stays in segment", nil, []string{"/tag/*"}, "", "https://example.com/tag/go/page/2", true, ""},
+		{"leading recursive glob", nil, []string{"**/print"}, "", "https://example.com/docs/a/print", false, "**/print"},
+		{"glob ignores query", []string{"/shop/*"}, nil, "", "https://example.com/shop/shoes?colour=red", true, ""},
+		{"anchored regex", nil, []string{`re:/p/\d+`}, "", "https://example.com/p/42", false, `re:/p/\d+`},
+		{"regex is anchored", nil, []string{`re:/p/\d+`}, "", "https://example.com/p/42/reviews", true, ""},
+		{"regex sees query", nil, []string{`re:/search\?.*`}, "", "https://example.com/search?q=x", false, `re:/search\?.*`},
+		{"query present", nil, []string{"query:page"}, "", "https://example.com/list?page=3", false, "query:page"},
+		{"query absent", nil, []string{"query:page"}, "", "https://example.com/list", true, ""},
+		{"query value", nil, []string{"query:sort=price"}, "", "https://example.com/list?sort=name", true, ""},
+		{"query value glob", nil, []string{"query:sort=price*"}, "", "https://example.com/list?sort=price_desc", false, "query:sort=price*"},
+		{"substring", nil, []string{"/admin"}, "", "https://example.com/wp/admin/x", false, "/admin"},
+		{"first exclude reported", nil, []string{"/a/**", "/a/b"}, "", "https://example.com/a/b", false, "/a/**"},
+		{"exclude wins by default", []string{"/blog/**"}, []string{"/blog/drafts/**"}, "", "https://example.com/blog/drafts/x", false, "/blog/drafts/**"},
+		{"include wins", []string{"/blog/drafts/keep"}, []string{"/blog/**"}, RulePrecedenceInclude, "https://example.com/blog/drafts/keep", true, ""},
+		{"include precedence still excludes", []string{"/blog/drafts/keep"}, []string{"/blog/**"}, RulePrecedenceInclude, "https://example.com/blog/other", false, "/blog/**"},
+		{"include precedence keeps unmatched", []string{"/blog/drafts/keep"}, []string{"/blog/**"}, RulePrecedenceInclude, "https://example.com/about", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := CompileURLRules(tt.include, tt.exclude, tt.precedence)
+			if err != nil {
+				t.Fatalf("CompileURLRules: %v", err)
+			}
+			keep, rule := rules.Match(tt.url)
+			if keep != tt.wantKeep || rule != tt.wantRule {
+				t.Errorf("Match(%q) = %v, %q, want %v, %q", tt.url, keep, rule, tt.wantKeep, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestCompileURLRulesErrors(t *testing.T) {
+	if _, err := CompileURLRules(nil, []string{"re:(unclosed"}, ""); err == nil {
+		t.Error("expected an error for an invalid regex")
+	}
+	if _, err := CompileURLRules([]string{"query:=x"}, nil, ""); err == nil {
+		t.Error("expected an error for a query rule without a name")
+	}
+	if _, err := CompileURLRules(nil, nil, "both"); err == nil {
+		t.Error("expected an error for an unknown precedence")
+	}
+}
+
+func TestURLRulesFilter(t *testing.T) {
+	rules, err := CompileURLRules([]string{"/docs/**"}, []string{"query:print"}, "")
+	if err != nil {
+		t.Fatalf("CompileURLRules: %v", err)
+	}
+	kept, excluded := rules.Filter([]string{
+		"https://example.com/docs/a",
+		"https://example.com/docs/a?print=1",
+		"https://example.com/about",
+		"https://example.com/docs/b",
+	})
+
+	wantKept := []string{"https://example.com/docs/a", "https://example.com/docs/b"}
+	if !reflect.DeepEqual(kept, wantKept) {
+		t.Errorf("kept = %v, want %v", kept, wantKept)
+	}
+	wantExcluded := map[string][]string{
+		"query:print":   {"https://example.com/docs/a?print=1"},
+		NotIncludedRule: {"https://example.com/about"},
+	}
+	if !reflect.DeepEqual(excluded, wantExcluded) {
+		t.Errorf("excluded = %v, want %v", excluded, wantExcluded)
+	}
+}
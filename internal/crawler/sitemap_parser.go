@@ -0,0 +1,413 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// MaxSitemapSize is the largest uncompressed sitemap the sitemaps.org protocol
+// allows. Bodies are cut off at this size after decompression, so a small
+// compressed file can't expand without bound.
+const MaxSitemapSize = 50 << 20
+
+// ErrSitemapTooLarge is returned when a sitemap exceeds MaxSitemapSize
+var ErrSitemapTooLarge = fmt.Errorf("sitemap exceeds %d bytes uncompressed", MaxSitemapSize)
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// DefaultSitemapPriority is the priority the sitemaps.org protocol gives URLs
+// that don't declare one
+const DefaultSitemapPriority = 0.5
+
+// sitemapChangeFreqs are the valid <changefreq> values
+var sitemapChangeFreqs = map[string]bool{
+	"always": true, "hourly": true, "daily": true, "weekly": true,
+	"monthly": true, "yearly": true, "never": true,
+}
+
+// SitemapEntry is a single <url> or <sitemap> element read from a sitemap
+type SitemapEntry struct {
+	Loc        string
+	Index      bool      // True for child sitemaps listed in a <sitemapindex>
+	LastMod    time.Time // Zero when missing or unparseable
+	ChangeFreq string    // Empty when missing or invalid
+	Priority   float64   // DefaultSitemapPriority when missing or invalid
+
+	// Sitemap extensions listed inside a <url>
+	Alternates []string // hreflang translations from xhtml:link
+	Images     []string // image:loc locations
+	Videos     []string // video:content_loc and video:thumbnail_loc locations
+}
+
+// Sitemap is a <sitemap> element of a sitemap index
+type Sitemap struct {
+	XMLName xml.Name `xml:"sitemap"`
+	Loc     string   `xml:"loc"`
+	LastMod string   `xml:"lastmod"`
+}
+
+// URL is a <url> element of a urlset
+type URL struct {
+	XMLName    xml.Name `xml:"url"`
+	Loc        string   `xml:"loc"`
+	LastMod    string   `xml:"lastmod"`
+	ChangeFreq string   `xml:"changefreq"`
+	Priority   string   `xml:"priority"`
+
+	Links  []AlternateLink `xml:"link"`
+	Images []SitemapImage  `xml:"image"`
+	Videos []SitemapVideo  `xml:"video"`
+}
+
+// AlternateLink is an xhtml:link naming a translation of the page
+type AlternateLink struct {
+	Rel      string `xml:"rel,attr"`
+	Hreflang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+// SitemapImage is an image:image from the image sitemap extension
+type SitemapImage struct {
+	Loc string `xml:"loc"`
+}
+
+// SitemapVideo is a video:video from the video sitemap extension
+type SitemapVideo struct {
+	ContentLoc   string `xml:"content_loc"`
+	ThumbnailLoc string `xml:"thumbnail_loc"`
+}
+
+// FeedItem is an RSS 2.0 or RSS 1.0 <item>
+type FeedItem struct {
+	Link    string `xml:"link"`
+	GUID    string `xml:"guid"`
+	PubDate string `xml:"pubDate"`
+	Date    string `xml:"date"` // Dublin Core dc:date used by RSS 1.0
+}
+
+// AtomEntry is an Atom <entry>
+type AtomEntry struct {
+	Links     []AtomLink `xml:"link"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+}
+
+// AtomLink is an Atom <link>
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+// Sitemap formats recognised by SitemapParser
+const (
+	SitemapFormatXML  = "xml"
+	SitemapFormatText = "text"
+	SitemapFormatRSS  = "rss"
+	SitemapFormatAtom = "atom"
+)
+
+// sniffLength is how much of a body is examined to tell XML from text
+const sniffLength = 512
+
+// SitemapParser reads sitemap entries one at a time from a stream, so large
+// sitemaps are never held in memory. XML sitemaps, RSS and Atom feeds and
+// plain-text sitemaps (one URL per line) are told apart by their content.
+// XML elements are matched on their local name, so namespace prefixes are
+// ignored, and entities and CDATA are decoded by encoding/xml. The decoder is
+// lenient: bare ampersands and unknown entities in URLs are kept as written,
+// and missing end tags are tolerated.
+type SitemapParser struct {
+	decoder *xml.Decoder
+	text    *bufio.Scanner
+	format  string
+}
+
+// NewSitemapParser creates a parser reading from r
+func NewSitemapParser(r io.Reader) *SitemapParser {
+	buffered := bufio.NewReader(r)
+	if !looksLikeXML(buffered) {
+		scanner := bufio.NewScanner(buffered)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+		return &SitemapParser{text: scanner, format: SitemapFormatText}
+	}
+
+	decoder := xml.NewDecoder(buffered)
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = sitemapCharsetReader
+	return &SitemapParser{decoder: decoder, format: SitemapFormatXML}
+}
+
+// Format reports what kind of sitemap is being read. XML documents are
+// reported as RSS or Atom once their root element has been read.
+func (p *SitemapParser) Format() string {
+	return p.format
+}
+
+// looksLikeXML reports whether the first non-space character is '<'
+func looksLikeXML(r *bufio.Reader) bool {
+	head, _ := r.Peek(sniffLength)
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	head = bytes.TrimLeft(head, " \t\r\n")
+	return len(head) == 0 || head[0] == '<'
+}
+
+// Next returns the next entry with a non-empty location, or io.EOF when the
+// sitemap has been read
+func (p *SitemapParser) Next() (SitemapEntry, error) {
+	if p.text != nil {
+		return p.nextLine()
+	}
+
+	for {
+		token, err := p.decoder.Token()
+		if err != nil {
+			return SitemapEntry{}, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		var entry SitemapEntry
+		switch start.Name.Local {
+		case "rss", "RDF":
+			p.format = SitemapFormatRSS
+			continue
+		case "feed":
+			p.format = SitemapFormatAtom
+			continue
+		case "item":
+			var item FeedItem
+			if err := p.decoder.DecodeElement(&item, &start); err != nil {
+				return SitemapEntry{}, fmt.Errorf("failed to decode feed item: %w", err)
+			}
+			entry = SitemapEntry{Loc: item.Link, LastMod: parseLastMod(item.PubDate), Priority: DefaultSitemapPriority}
+			if strings.TrimSpace(entry.Loc) == "" && isAbsoluteHTTPURL(strings.TrimSpace(item.GUID)) {
+				entry.Loc = item.GUID
+			}
+			if entry.LastMod.IsZero() {
+				entry.LastMod = parseLastMod(item.Date)
+			}
+		case "entry":
+			var atom AtomEntry
+			if err := p.decoder.DecodeElement(&atom, &start); err != nil {
+				return SitemapEntry{}, fmt.Errorf("failed to decode feed entry: %w", err)
+			}
+			entry = SitemapEntry{Loc: atom.alternate(), LastMod: parseLastMod(atom.Updated), Priority: DefaultSitemapPriority}
+			if entry.LastMod.IsZero() {
+				entry.LastMod = parseLastMod(atom.Published)
+			}
+		case "url":
+			var u URL
+			if err := p.decoder.DecodeElement(&u, &start); err != nil {
+				return SitemapEntry{}, fmt.Errorf("failed to decode url: %w", err)
+			}
+			entry = SitemapEntry{
+				Loc:        u.Loc,
+				LastMod:    parseLastMod(u.LastMod),
+				ChangeFreq: parseChangeFreq(u.ChangeFreq),
+				Priority:   parsePriority(u.Priority),
+			}
+			u.addExtensions(&entry)
+		case "sitemap":
+			var s Sitemap
+			if err := p.decoder.DecodeElement(&s, &start); err != nil {
+				return SitemapEntry{}, fmt.Errorf("failed to decode sitemap: %w", err)
+			}
+			entry = SitemapEntry{Loc: s.Loc, Index: true, LastMod: parseLastMod(s.LastMod)}
+		default:
+			// urlset, sitemapindex and unknown wrappers are descended into
+			continue
+		}
+
+		if entry.Loc = strings.TrimSpace(entry.Loc); entry.Loc != "" {
+			return entry, nil
+		}
+	}
+}
+
+// addExtensions copies the alternates and media locations of the <url>
+func (u URL) addExtensions(entry *SitemapEntry) {
+	for _, link := range u.Links {
+		if strings.EqualFold(link.Rel, "alternate") && link.Hreflang != "" {
+			if href := strings.TrimSpace(link.Href); href != "" {
+				entry.Alternates = append(entry.Alternates, href)
+			}
+		}
+	}
+	for _, image := range u.Images {
+		if loc := strings.TrimSpace(image.Loc); loc != "" {
+			entry.Images = append(entry.Images, loc)
+		}
+	}
+	for _, video := range u.Videos {
+		for _, loc := range []string{video.ContentLoc, video.ThumbnailLoc} {
+			if loc = strings.TrimSpace(loc); loc != "" {
+				entry.Videos = append(entry.Videos, loc)
+			}
+		}
+	}
+}
+
+// nextLine returns the next URL from a text sitemap. Blank lines, comments and
+// lines that aren't absolute http(s) URLs are skipped.
+func (p *SitemapParser) nextLine() (SitemapEntry, error) {
+	for p.text.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(p.text.Text(), "\ufeff"))
+		if isAbsoluteHTTPURL(line) {
+			return SitemapEntry{Loc: line, Priority: DefaultSitemapPriority}, nil
+		}
+	}
+	if err := p.text.Err(); err != nil {
+		return SitemapEntry{}, err
+	}
+	return SitemapEntry{}, io.EOF
+}
+
+// alternate returns the entry's page link: the rel="alternate" link, or the
+// first link without a rel
+func (e AtomEntry) alternate() string {
+	for _, link := range e.Links {
+		if link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+	for _, link := range e.Links {
+		if link.Rel == "" {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+func isAbsoluteHTTPURL(s string) bool {
+	lower := strings.ToLower(s)
+	return (strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")) &&
+		!strings.ContainsAny(s, " \t")
+}
+
+// lastModLayouts are the W3C Datetime forms allowed in <lastmod>, plus the
+// space-separated form some generators emit and the RFC 822 dates of RSS
+var lastModLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+	time.RFC1123Z, // RSS pubDate
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+}
+
+// parseLastMod parses a <lastmod> value, returning the zero time if it can't
+func parseLastMod(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	for _, layout := range lastModLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
+
+// parseChangeFreq returns the lowercased <changefreq> value if it is valid
+func parseChangeFreq(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if sitemapChangeFreqs[value] {
+		return value
+	}
+	return ""
+}
+
+// parsePriority parses a <priority> value between 0.0 and 1.0
+func parsePriority(value string) float64 {
+	priority, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || priority < 0 || priority > 1 {
+		return DefaultSitemapPriority
+	}
+	return priority
+}
+
+// sitemapBody returns a reader over the response's uncompressed sitemap,
+// limited to MaxSitemapSize. Responses are decompressed when they're gzip
+// data: .gz URLs, application/x-gzip types and Content-Encoding: gzip all
+// serve gzip streams. The stream is recognised by its magic bytes rather than
+// those headers, because the transport may already have decoded a
+// Content-Encoding: gzip response and some servers send .gz files as plain XML.
+func sitemapBody(resp *http.Response) (io.Reader, error) {
+	buffered := bufio.NewReader(resp.Body)
+	magic, err := buffered.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	var body io.Reader = buffered
+	if bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress sitemap: %w", err)
+		}
+		body = gz
+	}
+
+	return &sitemapSizeLimiter{r: body, remaining: MaxSitemapSize}, nil
+}
+
+// sitemapSizeLimiter fails with ErrSitemapTooLarge instead of silently
+// truncating, so callers know the sitemap was incomplete
+type sitemapSizeLimiter struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *sitemapSizeLimiter) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// Only an error if there is more data to read
+		var probe [1]byte
+		n, err := l.r.Read(probe[:])
+		if n > 0 {
+			return 0, ErrSitemapTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// sitemapCharsetReader converts the single-byte encodings sometimes declared by
+// sitemaps to UTF-8. encoding/xml handles UTF-8 itself. Windows-1252 differs from
+// ISO-8859-1 in 0x80-0x9F, where it has curly quotes, dashes and the euro sign.
+func sitemapCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf8", "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "iso8859-1", "latin1", "latin-1":
+		return charmap.ISO8859_1.NewDecoder().Reader(input), nil
+	case "windows-1252", "cp1252":
+		return charmap.Windows1252.NewDecoder().Reader(input), nil
+	}
+	return nil, fmt.Errorf("unsupported sitemap charset: %s", charset)
+}
@@ -0,0 +1,355 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSitemapParserFixtures parses each file in testdata/sitemaps and compares
+// its format and entries with the matching .golden file
+func TestSitemapParserFixtures(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "sitemaps", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no sitemap fixtures found")
+	}
+
+	for _, fixture := range fixtures {
+		if filepath.Ext(fixture) == ".golden" {
+			continue
+		}
+		base := strings.TrimSuffix(fixture, filepath.Ext(fixture))
+		t.Run(filepath.Base(base), func(t *testing.T) {
+			f, err := os.Open(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			parser := NewSitemapParser(f)
+			var entries []string
+			for {
+				entry, err := parser.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Logf("stopped at error: %v", err)
+					break
+				}
+				kind := "url"
+				if entry.Index {
+					kind = "sitemap"
+				}
+				entries = append(entries, kind+" "+entry.Loc)
+			}
+			got := append([]string{"format " + parser.Format()}, entries...)
+
+			golden, err := os.ReadFile(base + ".golden")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := strings.Split(strings.TrimSpace(string(golden)), "\n")
+
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("entries:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+		})
+	}
+}
+
+func TestParseSitemapIndex(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%[1]s/pages.xml</loc></sitemap><sitemap><loc>%[1]s/missing.xml</loc></sitemap></sitemapindex>`, server.URL)
+		case "/pages.xml":
+			fmt.Fprintf(w, `<urlset><url><loc>%[1]s/a?x=1&amp;y=2</loc></url><url><loc>%[1]s/b</loc></url></urlset>`, server.URL)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := New(DefaultConfig())
+	urls, err := c.ParseSitemap(context.Background(), server.URL+"/sitemap.xml")
+	if err != nil {
+		t.Fatalf("ParseSitemap: %v", err)
+	}
+
+	want := []string{server.URL + "/a?x=1&y=2", server.URL + "/b"}
+	if strings.Join(urls, " ") != strings.Join(want, " ") {
+		t.Errorf("urls = %v, want %v", urls, want)
+	}
+}
+
+func gzipBytes(t testing.TB, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseSitemapGzip(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap_index.xml.gz":
+			w.Header().Set("Content-Type", "application/x-gzip")
+			w.Write(gzipBytes(t, []byte(fmt.Sprintf(`<sitemapindex><sitemap><loc>%s/pages.xml</loc></sitemap></sitemapindex>`, server.URL))))
+		case "/pages.xml":
+			// Decoded by the transport, which asked for gzip itself
+			w.Header().Set("Content-Type", "application/xml")
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipBytes(t, []byte(fmt.Sprintf(`<urlset><url><loc>%s/encoded</loc></url></urlset>`, server.URL))))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := New(DefaultConfig())
+	urls, err := c.ParseSitemap(context.Background(), server.URL+"/sitemap_index.xml.gz")
+	if err != nil {
+		t.Fatalf("ParseSitemap: %v", err)
+	}
+	if len(urls) != 1 || urls[0] != server.URL+"/encoded" {
+		t.Errorf("urls = %v, want [%s/encoded]", urls, server.URL)
+	}
+}
+
+func TestSitemapBodyDecompressedLimit(t *testing.T) {
+	// A few hundred KB that expands past the 50MB limit
+	bomb := gzipBytes(t, append([]byte("<urlset><url><loc>https://example.com/</loc></url>"), bytes.Repeat([]byte(" "), MaxSitemapSize+1)...))
+
+	resp := &http.Response{
+		Body:    io.NopCloser(bytes.NewReader(bomb)),
+		Header:  http.Header{"Content-Type": []string{"application/x-gzip"}},
+		Request: httptest.NewRequest("GET", "https://example.com/sitemap.xml.gz", nil),
+	}
+	body, err := sitemapBody(resp)
+	if err != nil {
+		t.Fatalf("sitemapBody: %v", err)
+	}
+
+	n, err := io.Copy(io.Discard, body)
+	if !errors.Is(err, ErrSitemapTooLarge) {
+		t.Errorf("err = %v, want ErrSitemapTooLarge", err)
+	}
+	if n != MaxSitemapSize {
+		t.Errorf("read %d bytes, want %d", n, MaxSitemapSize)
+	}
+}
+
+func TestSitemapParserMetadata(t *testing.T) {
+	const sitemap = `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+		<url><loc>https://example.com/</loc><lastmod>2024-03-01T10:30:00+10:00</lastmod><changefreq>Daily</changefreq><priority>1.0</priority></url>
+		<url><loc>https://example.com/old</loc><lastmod>2023-06</lastmod><changefreq>sometimes</changefreq><priority>2</priority></url>
+		<url><loc>https://example.com/bare</loc></url>
+	</urlset>`
+
+	parser := NewSitemapParser(strings.NewReader(sitemap))
+	var entries []SitemapEntry
+	for {
+		entry, err := parser.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+
+	tests := []struct {
+		lastMod    time.Time
+		changeFreq string
+		priority   float64
+	}{
+		{time.Date(2024, 3, 1, 0, 30, 0, 0, time.UTC), "daily", 1.0},
+		{time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), "", DefaultSitemapPriority},
+		{time.Time{}, "", DefaultSitemapPriority},
+	}
+	for i, tt := range tests {
+		got := entries[i]
+		if !got.LastMod.Equal(tt.lastMod) {
+			t.Errorf("%s: lastmod = %v, want %v", got.Loc, got.LastMod, tt.lastMod)
+		}
+		if got.ChangeFreq != tt.changeFreq {
+			t.Errorf("%s: changefreq = %q, want %q", got.Loc, got.ChangeFreq, tt.changeFreq)
+		}
+		if got.Priority != tt.priority {
+			t.Errorf("%s: priority = %v, want %v", got.Loc, got.Priority, tt.priority)
+		}
+	}
+}
+
+func TestTraverseSitemaps(t *testing.T) {
+	var inFlight, maxInFlight int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			peak := atomic.LoadInt32(&maxInFlight)
+			if n <= peak || atomic.CompareAndSwapInt32(&maxInFlight, peak, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		switch r.URL.Path {
+		case "/index.xml":
+			// Lists itself, a nested index and ten page sitemaps
+			fmt.Fprint(w, `<sitemapindex>`)
+			fmt.Fprintf(w, `<sitemap><loc>%s/index.xml</loc></sitemap>`, server.URL)
+			fmt.Fprintf(w, `<sitemap><loc>%s/nested.xml</loc></sitemap>`, server.URL)
+			for i := 0; i < 10; i++ {
+				fmt.Fprintf(w, `<sitemap><loc>%s/pages-%d.xml</loc></sitemap>`, server.URL, i)
+			}
+			fmt.Fprint(w, `</sitemapindex>`)
+		case "/nested.xml":
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s/deep.xml</loc></sitemap><sitemap><loc>%s/index.xml</loc></sitemap></sitemapindex>`, server.URL, server.URL)
+		case "/deep.xml":
+			fmt.Fprintf(w, `<urlset><url><loc>%s/deep</loc></url></urlset>`, server.URL)
+		default:
+			var i int
+			if _, err := fmt.Sscanf(r.URL.Path, "/pages-%d.xml", &i); err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintf(w, `<urlset><url><loc>%s/page-%d-a</loc></url><url><loc>%s/page-%d-b</loc></url></urlset>`, server.URL, i, server.URL, i)
+		}
+	}))
+	defer server.Close()
+
+	c := New(DefaultConfig())
+	ctx := context.Background()
+
+	t.Run("cycles and concurrency", func(t *testing.T) {
+		atomic.StoreInt32(&maxInFlight, 0)
+		entries, results := c.TraverseSitemaps(ctx, []string{server.URL + "/index.xml"}, SitemapLimits{MaxDepth: 5, Concurrency: 3})
+		if len(entries) != 21 {
+			t.Errorf("got %d entries, want 21", len(entries))
+		}
+		// index, nested, ten page sitemaps and deep, each fetched once
+		if len(results) != 13 {
+			t.Errorf("got %d results, want 13", len(results))
+		}
+		if got := atomic.LoadInt32(&maxInFlight); got > 3 {
+			t.Errorf("max concurrent fetches = %d, want <= 3", got)
+		}
+	})
+
+	t.Run("depth limit", func(t *testing.T) {
+		entries, results := c.TraverseSitemaps(ctx, []string{server.URL + "/index.xml"}, SitemapLimits{MaxDepth: 1, Concurrency: 3})
+		if len(entries) != 20 {
+			t.Errorf("got %d entries, want 20", len(entries))
+		}
+		last := results[len(results)-1]
+		if last.URL != server.URL+"/deep.xml" || last.Depth != 2 || !strings.Contains(last.Error, "depth") {
+			t.Errorf("last result = %+v, want deep.xml depth error", last)
+		}
+	})
+
+	t.Run("url limit", func(t *testing.T) {
+		entries, results := c.TraverseSitemaps(ctx, []string{server.URL + "/index.xml"}, SitemapLimits{MaxDepth: 5, Concurrency: 1, MaxURLs: 5})
+		if len(entries) != 5 {
+			t.Errorf("got %d entries, want 5", len(entries))
+		}
+		limited := 0
+		for _, r := range results {
+			if strings.Contains(r.Error, "limit") {
+				limited++
+			}
+		}
+		if limited == 0 {
+			t.Error("no sitemap results report the URL limit")
+		}
+	})
+}
+
+func TestTraverseSitemapsUserAgent(t *testing.T) {
+	var userAgent atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent.Store(r.Header.Get("User-Agent"))
+		fmt.Fprint(w, `<urlset><url><loc>https://example.com/</loc></url></urlset>`)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.UserAgent = "TestBot/1.0"
+	New(config).TraverseSitemaps(context.Background(), []string{server.URL + "/sitemap.xml"}, SitemapLimits{MaxDepth: 1, Concurrency: 1})
+	if got, _ := userAgent.Load().(string); got != config.UserAgent {
+		t.Errorf("sitemap requested with User-Agent %q, want %q", got, config.UserAgent)
+	}
+}
+
+func TestSitemapParserFeedDates(t *testing.T) {
+	want := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+	for _, fixture := range []string{"rss.xml", "rss1-rdf.xml", "atom.xml"} {
+		f, err := os.Open(filepath.Join("testdata", "sitemaps", fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry, err := NewSitemapParser(f).Next()
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", fixture, err)
+		}
+		if !entry.LastMod.Equal(want) {
+			t.Errorf("%s: lastmod = %v, want %v", fixture, entry.LastMod, want)
+		}
+	}
+}
+
+func TestSitemapParserExtensions(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "sitemaps", "extensions.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	entry, err := NewSitemapParser(f).Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+
+	tests := map[string]struct {
+		got, want []string
+	}{
+		"alternates": {entry.Alternates, []string{"https://example.com/fr/gallery", "https://example.com/de/gallery"}},
+		"images":     {entry.Images, []string{"https://cdn.example.com/hero.jpg", "https://cdn.example.com/second.jpg"}},
+		"videos":     {entry.Videos, []string{"https://cdn.example.com/tour.mp4", "https://cdn.example.com/thumb.jpg"}},
+	}
+	for name, tt := range tests {
+		if strings.Join(tt.got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s = %v, want %v", name, tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SitemapLimits bounds a sitemap traversal
+type SitemapLimits struct {
+	MaxDepth    int // Levels of nested sitemap indexes followed; root sitemaps are depth 0
+	Concurrency int // Sitemaps fetched at once
+	MaxURLs     int // Page URLs collected before traversal stops (0 is unlimited)
+}
+
+// SitemapResult is the outcome of one sitemap in a traversal
+type SitemapResult struct {
+	URL        string `json:"url"`
+	ParentURL  string `json:"parent_url,omitempty"`
+	Depth      int    `json:"depth"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Format     string `json:"format,omitempty"` // xml, text, rss or atom
+	URLCount   int    `json:"url_count"`        // Page URLs listed in the sitemap
+	ChildCount int    `json:"child_count"`      // Child sitemaps listed in the sitemap
+	Error      string `json:"error,omitempty"`
+}
+
+// DefaultSitemapLimits returns the traversal limits from the crawler config
+func (c *Crawler) DefaultSitemapLimits() SitemapLimits {
+	return SitemapLimits{
+		MaxDepth:    c.config.SitemapMaxDepth,
+		Concurrency: c.config.SitemapConcurrency,
+		MaxURLs:     c.config.MaxSitemapURLs,
+	}
+}
+
+// pendingSitemap is a sitemap waiting to be fetched
+type pendingSitemap struct {
+	url    string
+	parent string
+}
+
+// fetchedSitemap holds what one sitemap fetch returned
+type fetchedSitemap struct {
+	entries  []SitemapEntry
+	children []string
+	result   SitemapResult
+}
+
+// TraverseSitemaps collects the page entries of the sitemaps and the indexes
+// they list. Each level of indexes is fetched with bounded concurrency, every
+// sitemap URL is fetched at most once so self-referencing indexes terminate,
+// and traversal stops at the depth and URL limits. Entries are deduplicated and
+// returned in sitemap order, with an outcome for every sitemap considered.
+func (c *Crawler) TraverseSitemaps(ctx context.Context, sitemapURLs []string, limits SitemapLimits) ([]SitemapEntry, []SitemapResult) {
+	client := c.CreateHTTPClient(30 * time.Second)
+	concurrency := max(limits.Concurrency, 1)
+
+	var entries []SitemapEntry
+	var results []SitemapResult
+	seenEntries := make(map[string]bool)
+	visited := make(map[string]bool)
+
+	var level []pendingSitemap
+	for _, sitemapURL := range sitemapURLs {
+		if !visited[sitemapURL] {
+			visited[sitemapURL] = true
+			level = append(level, pendingSitemap{url: sitemapURL})
+		}
+	}
+
+	limitReached := func() bool {
+		return limits.MaxURLs > 0 && len(entries) >= limits.MaxURLs
+	}
+
+	for depth := 0; len(level) > 0; depth++ {
+		if depth > limits.MaxDepth {
+			for _, p := range level {
+				results = append(results, SitemapResult{
+					URL:       p.url,
+					ParentURL: p.parent,
+					Depth:     depth,
+					Error:     fmt.Sprintf("sitemap index nesting exceeds maximum depth of %d", limits.MaxDepth),
+				})
+			}
+			log.Warn().Int("depth", depth).Int("sitemap_count", len(level)).Msg("Sitemap depth limit reached")
+			break
+		}
+
+		// URLs collected so far, so fetches can stop once the limit is reached
+		collected := int64(len(entries))
+		fetched := make([]fetchedSitemap, len(level))
+		sem := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
+		for i, p := range level {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, p pendingSitemap) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				if limits.MaxURLs > 0 && atomic.LoadInt64(&collected) >= int64(limits.MaxURLs) {
+					fetched[i].result = SitemapResult{URL: p.url, Error: urlLimitError(limits.MaxURLs)}
+					return
+				}
+				sitemapEntries, children, result := c.fetchSitemap(ctx, client, p.url)
+				atomic.AddInt64(&collected, int64(len(sitemapEntries)))
+				fetched[i] = fetchedSitemap{entries: sitemapEntries, children: children, result: result}
+			}(i, p)
+		}
+		wg.Wait()
+
+		var next []pendingSitemap
+		for i, f := range fetched {
+			result := f.result
+			result.ParentURL = level[i].parent
+			result.Depth = depth
+
+			for _, entry := range f.entries {
+				if limitReached() {
+					if result.Error == "" {
+						result.Error = urlLimitError(limits.MaxURLs)
+					}
+					break
+				}
+				if !seenEntries[entry.Loc] {
+					seenEntries[entry.Loc] = true
+					entries = append(entries, entry)
+				}
+			}
+
+			for _, child := range f.children {
+				if visited[child] {
+					log.Debug().Str("url", child).Str("parent_url", level[i].url).Msg("Skipping already visited sitemap")
+					continue
+				}
+				visited[child] = true
+				next = append(next, pendingSitemap{url: child, parent: level[i].url})
+			}
+
+			if result.Error != "" {
+				log.Warn().Str("url", result.URL).Str("error", result.Error).Msg("Sitemap not fully processed")
+			}
+			results = append(results, result)
+		}
+
+		// Children aren't fetched once the limit is reached
+		if limitReached() {
+			for _, p := range next {
+				results = append(results, SitemapResult{URL: p.url, ParentURL: p.parent, Depth: depth + 1, Error: urlLimitError(limits.MaxURLs)})
+			}
+			break
+		}
+		level = next
+	}
+
+	return entries, results
+}
+
+func urlLimitError(maxURLs int) string {
+	return fmt.Sprintf("sitemap URL limit of %d reached", maxURLs)
+}
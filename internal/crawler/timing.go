@@ -0,0 +1,74 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// requestTimer records the phases of a single request using httptrace.
+// Dials can finish on other goroutines after the response arrives, so fields are guarded.
+type requestTimer struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	reused       bool
+}
+
+// newRequestTimer returns a context that traces requests made with it into the timer
+func newRequestTimer(ctx context.Context) (context.Context, *requestTimer) {
+	t := &requestTimer{start: time.Now()}
+	mark := func(field *time.Time) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		// Happy Eyeballs may dial more than once, keep the first event
+		if field.IsZero() {
+			*field = time.Now()
+		}
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { mark(&t.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { mark(&t.dnsDone) },
+		ConnectStart:      func(_, _ string) { mark(&t.connectStart) },
+		ConnectDone:       func(_, _ string, _ error) { mark(&t.connectDone) },
+		TLSHandshakeStart: func() { mark(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { mark(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.reused = info.Reused
+		},
+		GotFirstResponseByte: func() { mark(&t.firstByte) },
+	}
+	return httptrace.WithClientTrace(ctx, trace), t
+}
+
+// apply records the timing breakdown on the result, with the body fully read at bodyDone
+func (t *requestTimer) apply(res *CrawlResult, bodyDone time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	res.DNSLookupTime = phaseMillis(t.dnsStart, t.dnsDone)
+	res.TCPConnectTime = phaseMillis(t.connectStart, t.connectDone)
+	res.TLSHandshakeTime = phaseMillis(t.tlsStart, t.tlsDone)
+	res.TTFB = phaseMillis(t.start, t.firstByte)
+	res.DownloadTime = phaseMillis(t.firstByte, bodyDone)
+	res.ConnReused = t.reused
+}
+
+// phaseMillis returns the milliseconds between two trace events, or 0 if either didn't happen
+func phaseMillis(start, end time.Time) int64 {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start).Milliseconds()
+}
@@ -1,5 +1,11 @@
 package crawler
 
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
 // CrawlResult represents the result of a URL crawl operation
 type CrawlResult struct {
 	URL          string   // The URL that was crawled
@@ -7,12 +13,76 @@ type CrawlResult struct {
 	StatusCode   int      // HTTP status code
 	Error        string   // Error message if any
 	Warning      string   // Warning message if any
-	CacheStatus  string   // Cache status (e.g., HIT, MISS)
+	WarningType  string   // Category of the warning (e.g., soft_404, cache_bypass)
+	CacheStatus  string   // Normalised cache status (e.g., HIT, MISS)
 	ContentType  string   // Content type of the response
 	Timestamp    int64    // Unix timestamp of the crawl
 	RetryCount   int      // Number of retries performed
 	SkippedCrawl bool     // Whether full crawl was skipped due to cache hit
 	Links        []string // Extracted hyperlinks (including PDFs/docs)
+	Assets       []string // Stylesheets, scripts, images, fonts and media the response references
+	Canonical    string   // Canonical URL the page declares in a <link> or Link header
+	Nofollow     bool     // Whether meta robots or X-Robots-Tag asks for the page's links not to be followed
+	Edge         string   // Edge IP the request was pinned to, if any
+
+	// Timing breakdown of the recorded request, in milliseconds
+	DNSLookupTime    int64 // DNS resolution
+	TCPConnectTime   int64 // TCP connection establishment
+	TLSHandshakeTime int64 // TLS handshake
+	TTFB             int64 // Time from sending the request to the first response byte
+	DownloadTime     int64 // Time from the first response byte to the end of the body
+	ConnReused       bool  // Whether a pooled connection was reused (no DNS, connect or TLS)
+
+	// Body accounting
+	BytesTransferred int64 // Body bytes read across all requests for the URL
+	ContentLength    int64 // Content-Length reported by the server (-1 if unknown)
+	BodyTruncated    bool  // Whether reading stopped at the maximum body size
+
+	// Retry details
+	AttemptErrors []string // Error from each failed attempt, in order
+	retryAfter    string   // Retry-After header of the response
+
+	// Cache detection
+	CacheStatusRaw string // Raw cache status header value
+	CDN            string // CDN that reported the cache status
+
+	// Cache verification
+	FirstCacheStatus string // Cache status returned by the first request
+	CacheAttempts    int    // Number of requests made to reach the final cache status
+	CacheVerified    bool   // Whether the edge confirmed a cache HIT
+}
+
+// Warning categories recorded in CrawlResult.WarningType
+const (
+	WarningEmptyBody     = "empty_body"
+	WarningSoft404       = "soft_404"
+	WarningSmallHTML     = "small_html"
+	WarningInvalidJSON   = "invalid_json"
+	WarningJSONError     = "json_error"
+	WarningTextError     = "text_error"
+	WarningCacheStale    = "cache_stale"
+	WarningCacheExpired  = "cache_expired"
+	WarningCacheBypass   = "cache_bypass"
+	WarningCacheDynamic  = "cache_dynamic"
+	WarningNoCacheHeader = "no_cache_header"
+	WarningUnknownCache  = "unknown_cache_status"
+)
+
+// WarmOptions controls how a single URL is warmed
+type WarmOptions struct {
+	FindLinks       bool                            // Whether to extract links from the response body
+	FindAssets      bool                            // Whether to extract asset references from HTML and CSS bodies
+	RespectNofollow bool                            // Leave out rel="nofollow" links, and every link of pages marked nofollow
+	VerifyCache     bool                            // Re-request the URL until the edge reports a HIT
+	VerifyAttempts  int                             // Maximum number of follow-up requests when verifying
+	VerifyDelay     time.Duration                   // Delay between follow-up requests when verifying
+	MaxBodySize     int64                           // Maximum body bytes to read (0 uses the crawler default, -1 is unlimited)
+	Headers         map[string]string               // Extra request headers, e.g. to warm a specific cache-key variant
+	Edge            string                          // IP address (optionally with port) to connect to instead of resolving the host
+	EdgeHost        string                          // Host pinned to Edge, other hosts resolve normally (empty uses the URL's host)
+	Auth            *RequestAuth                    // Credentials for a protected site (nil uses the crawler's configured auth)
+	Jar             http.CookieJar                  // Cookies kept across a job's requests (nil uses a jar seeded from Auth)
+	Wait            func(ctx context.Context) error // Called before each request, e.g. to honour a Crawl-delay
 }
 
 // CrawlOptions defines configuration options for a crawl operation
@@ -191,6 +191,9 @@ func setupSchema(db *sql.DB) error {
 			domain_id INTEGER NOT NULL REFERENCES domains(id),
 			path TEXT NOT NULL,
 			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			lastmod TIMESTAMP,
+			changefreq TEXT,
+			priority REAL,
 			UNIQUE(domain_id, path)
 		)
 	`)
@@ -210,6 +213,7 @@ func setupSchema(db *sql.DB) error {
 			total_tasks INTEGER NOT NULL DEFAULT 0,
 			completed_tasks INTEGER NOT NULL DEFAULT 0,
 			failed_tasks INTEGER NOT NULL DEFAULT 0,
+			skipped_tasks INTEGER NOT NULL DEFAULT 0,
 			created_at TIMESTAMP NOT NULL,
 			started_at TIMESTAMP,
 			completed_at TIMESTAMP,
@@ -218,7 +222,37 @@ func setupSchema(db *sql.DB) error {
 			max_pages INTEGER NOT NULL,
 			include_paths TEXT,
 			exclude_paths TEXT,
-			required_workers INTEGER DEFAULT 0
+			required_workers INTEGER DEFAULT 0,
+			error_message TEXT,
+			verify_cache BOOLEAN NOT NULL DEFAULT FALSE,
+			verify_attempts INTEGER NOT NULL DEFAULT 0,
+			verify_delay_ms INTEGER NOT NULL DEFAULT 0,
+			warmed_tasks INTEGER NOT NULL DEFAULT 0,
+			max_body_size BIGINT NOT NULL DEFAULT 0,
+			total_bytes BIGINT NOT NULL DEFAULT 0,
+			variants TEXT,
+			edges TEXT,
+			dns_servers TEXT,
+			auth TEXT,
+			modified_since TIMESTAMP,
+			max_sitemap_urls INTEGER NOT NULL DEFAULT 0,
+			feed_urls TEXT,
+			include_hreflang BOOLEAN NOT NULL DEFAULT FALSE,
+			include_images BOOLEAN NOT NULL DEFAULT FALSE,
+			include_videos BOOLEAN NOT NULL DEFAULT FALSE,
+			respect_robots BOOLEAN NOT NULL DEFAULT FALSE,
+			crawl_delay_ms INTEGER NOT NULL DEFAULT 0,
+			sitemap_urls TEXT,
+			base_url TEXT,
+			redirects TEXT,
+			only_added_urls BOOLEAN NOT NULL DEFAULT FALSE,
+			find_assets BOOLEAN NOT NULL DEFAULT FALSE,
+			asset_hosts TEXT,
+			url_policy TEXT,
+			respect_nofollow BOOLEAN NOT NULL DEFAULT FALSE,
+			enqueue_canonical BOOLEAN NOT NULL DEFAULT FALSE,
+			max_depth INTEGER NOT NULL DEFAULT 0,
+			rule_precedence TEXT
 		)
 	`)
 	if err != nil {
@@ -238,12 +272,36 @@ func setupSchema(db *sql.DB) error {
 			completed_at TIMESTAMP,
 			retry_count INTEGER NOT NULL,
 			error TEXT,
+			skip_reason TEXT,
 			source_type TEXT NOT NULL,
 			source_url TEXT,
 			status_code INTEGER,
 			response_time BIGINT,
 			cache_status TEXT,
 			content_type TEXT,
+			first_cache_status TEXT,
+			cache_attempts INTEGER NOT NULL DEFAULT 0,
+			cache_verified BOOLEAN NOT NULL DEFAULT FALSE,
+			cache_status_raw TEXT,
+			cdn TEXT,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			attempt_errors TEXT,
+			warning TEXT,
+			warning_type TEXT,
+			variant_failures TEXT,
+			bytes_transferred BIGINT NOT NULL DEFAULT 0,
+			content_length BIGINT,
+			body_truncated BOOLEAN NOT NULL DEFAULT FALSE,
+			dns_lookup_time BIGINT,
+			tcp_connect_time BIGINT,
+			tls_handshake_time BIGINT,
+			ttfb BIGINT,
+			download_time BIGINT,
+			conn_reused BOOLEAN,
+			priority REAL NOT NULL DEFAULT 0,
+			canonical_url TEXT,
+			depth INTEGER NOT NULL DEFAULT 0,
+			parent_task_id TEXT,
 			FOREIGN KEY (job_id) REFERENCES jobs(id)
 		)
 	`)
@@ -251,6 +309,141 @@ func setupSchema(db *sql.DB) error {
 		return fmt.Errorf("failed to create tasks table: %w", err)
 	}
 
+	// Create task_results table for per-variant results of a task
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS task_results (
+			id SERIAL PRIMARY KEY,
+			task_id TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+			job_id TEXT NOT NULL REFERENCES jobs(id),
+			variant TEXT NOT NULL,
+			edge TEXT NOT NULL DEFAULT '',
+			status_code INTEGER,
+			response_time BIGINT,
+			cache_status TEXT,
+			first_cache_status TEXT,
+			cache_verified BOOLEAN NOT NULL DEFAULT FALSE,
+			bytes_transferred BIGINT NOT NULL DEFAULT 0,
+			error TEXT,
+			created_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create task_results table: %w", err)
+	}
+
+	// Create job_sitemaps table for the outcome of each sitemap a job read
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS job_sitemaps (
+			id SERIAL PRIMARY KEY,
+			job_id TEXT NOT NULL REFERENCES jobs(id),
+			url TEXT NOT NULL,
+			parent_url TEXT,
+			depth INTEGER NOT NULL DEFAULT 0,
+			status_code INTEGER,
+			format TEXT,
+			url_count INTEGER NOT NULL DEFAULT 0,
+			child_count INTEGER NOT NULL DEFAULT 0,
+			error TEXT,
+			created_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create job_sitemaps table: %w", err)
+	}
+
+	// Create job_urls table for the URLs each job found, by source
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS job_urls (
+			job_id TEXT NOT NULL REFERENCES jobs(id),
+			source_type TEXT NOT NULL,
+			path TEXT NOT NULL,
+			PRIMARY KEY (job_id, source_type, path)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create job_urls table: %w", err)
+	}
+
+	// Create job_excluded_urls table for the URLs each include/exclude rule dropped
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS job_excluded_urls (
+			job_id TEXT NOT NULL REFERENCES jobs(id),
+			rule TEXT NOT NULL,
+			path TEXT NOT NULL,
+			PRIMARY KEY (job_id, rule, path)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create job_excluded_urls table: %w", err)
+	}
+
+	// Add columns introduced after the initial schema to existing databases
+	migrations := []string{
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS error_message TEXT`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS verify_cache BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS verify_attempts INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS verify_delay_ms INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS warmed_tasks INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS max_body_size BIGINT NOT NULL DEFAULT 0`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS total_bytes BIGINT NOT NULL DEFAULT 0`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS variants TEXT`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS edges TEXT`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS dns_servers TEXT`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS auth TEXT`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS modified_since TIMESTAMP`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS max_sitemap_urls INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS feed_urls TEXT`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS include_hreflang BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS include_images BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS include_videos BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS respect_robots BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS crawl_delay_ms INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS skipped_tasks INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS sitemap_urls TEXT`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS base_url TEXT`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS redirects TEXT`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS only_added_urls BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS find_assets BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS asset_hosts TEXT`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS url_policy TEXT`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS respect_nofollow BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS enqueue_canonical BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS max_depth INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS rule_precedence TEXT`,
+		`ALTER TABLE pages ADD COLUMN IF NOT EXISTS lastmod TIMESTAMP`,
+		`ALTER TABLE pages ADD COLUMN IF NOT EXISTS changefreq TEXT`,
+		`ALTER TABLE pages ADD COLUMN IF NOT EXISTS priority REAL`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS first_cache_status TEXT`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS cache_attempts INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS cache_verified BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS cache_status_raw TEXT`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS cdn TEXT`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS attempt_errors TEXT`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS warning TEXT`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS warning_type TEXT`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS bytes_transferred BIGINT NOT NULL DEFAULT 0`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS content_length BIGINT`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS body_truncated BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS dns_lookup_time BIGINT`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS tcp_connect_time BIGINT`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS tls_handshake_time BIGINT`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS ttfb BIGINT`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS download_time BIGINT`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS conn_reused BOOLEAN`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS priority REAL NOT NULL DEFAULT 0`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS skip_reason TEXT`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS canonical_url TEXT`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS depth INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS parent_task_id TEXT`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS variant_failures TEXT`,
+	}
+	for _, migration := range migrations {
+		if _, err = db.Exec(migration); err != nil {
+			return fmt.Errorf("failed to migrate schema: %w", err)
+		}
+	}
+
 	// Add a unique constraint to prevent duplicate tasks for same page in a job
 	_, err = db.Exec(`
 		CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_job_page_unique 
@@ -266,6 +459,21 @@ func setupSchema(db *sql.DB) error {
 		return fmt.Errorf("failed to create task job_id index: %w", err)
 	}
 
+	_, err = db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_task_results_task_variant_edge ON task_results(task_id, variant, edge)`)
+	if err != nil {
+		return fmt.Errorf("failed to create task_results task/variant/edge index: %w", err)
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_task_results_job_id ON task_results(job_id)`)
+	if err != nil {
+		return fmt.Errorf("failed to create task_results job_id index: %w", err)
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_job_sitemaps_job_id ON job_sitemaps(job_id)`)
+	if err != nil {
+		return fmt.Errorf("failed to create job_sitemaps job_id index: %w", err)
+	}
+
 	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_tasks_status ON tasks(status)`)
 	if err != nil {
 		return fmt.Errorf("failed to create task status index: %w", err)
@@ -277,8 +485,14 @@ func setupSchema(db *sql.DB) error {
 		return fmt.Errorf("failed to create task status/created_at index: %w", err)
 	}
 
+	// Pending tasks are claimed in sitemap priority order
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_tasks_status_priority ON tasks(status, priority DESC, created_at)`)
+	if err != nil {
+		return fmt.Errorf("failed to create task status/priority index: %w", err)
+	}
+
 	// Enable Row-Level Security for all tables
-	tables := []string{"domains", "pages", "jobs", "tasks"}
+	tables := []string{"domains", "pages", "jobs", "tasks", "task_results", "job_sitemaps", "job_urls", "job_excluded_urls"}
 	for _, table := range tables {
 		// Enable RLS on the table
 		_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY", table))
@@ -305,7 +519,27 @@ func (db *DB) ResetSchema() error {
 	log.Warn().Msg("Resetting PostgreSQL schema")
 
 	// Drop tables in reverse order to respect foreign keys
-	_, err := db.client.Exec(`DROP TABLE IF EXISTS tasks`)
+	_, err := db.client.Exec(`DROP TABLE IF EXISTS task_results`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.client.Exec(`DROP TABLE IF EXISTS job_sitemaps`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.client.Exec(`DROP TABLE IF EXISTS job_urls`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.client.Exec(`DROP TABLE IF EXISTS job_excluded_urls`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.client.Exec(`DROP TABLE IF EXISTS tasks`)
 	if err != nil {
 		return err
 	}
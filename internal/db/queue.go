@@ -47,24 +47,61 @@ func (q *DbQueue) Execute(ctx context.Context, fn func(*sql.Tx) error) error {
 
 // Task represents a task in the queue
 type Task struct {
-	ID          string
-	JobID       string
-	PageID      int
-	Path        string
-	Status      string
-	CreatedAt   time.Time
-	StartedAt   time.Time
-	CompletedAt time.Time
-	RetryCount  int
-	Error       string
-	SourceType  string
-	SourceURL   string
+	ID           string
+	JobID        string
+	PageID       int
+	Path         string
+	Status       string
+	CreatedAt    time.Time
+	StartedAt    time.Time
+	CompletedAt  time.Time
+	RetryCount   int
+	Error        string
+	SkipReason   string
+	SourceType   string
+	SourceURL    string
+	Depth        int
+	ParentTaskID string
 
 	// Result data
 	StatusCode   int
 	ResponseTime int64
 	CacheStatus  string
 	ContentType  string
+	CanonicalURL string
+
+	// Content or cache warning for a completed task
+	Warning     string
+	WarningType string
+
+	// Failed requests for the task's other variants and edges
+	VariantFailures string
+
+	// Request attempts made while warming
+	Attempts      int
+	AttemptErrors []string
+
+	// Body accounting
+	BytesTransferred int64
+	ContentLength    int64
+	BodyTruncated    bool
+
+	// Timing breakdown in milliseconds
+	DNSLookupTime    int64
+	TCPConnectTime   int64
+	TLSHandshakeTime int64
+	TTFB             int64
+	DownloadTime     int64
+	ConnReused       bool
+
+	// Cache detection
+	CacheStatusRaw string
+	CDN            string
+
+	// Cache verification
+	FirstCacheStatus string
+	CacheAttempts    int
+	CacheVerified    bool
 }
 
 // GetNextTask gets a pending task using row-level locking
@@ -75,7 +112,8 @@ func (q *DbQueue) GetNextTask(ctx context.Context, jobID string) (*Task, error)
 		// Query for a pending task with FOR UPDATE SKIP LOCKED
 		// This allows concurrent workers to each get different tasks
 		query := `
-			SELECT id, job_id, page_id, path, created_at, retry_count, source_type, source_url 
+			SELECT id, job_id, page_id, path, created_at, retry_count, source_type, source_url,
+				depth, COALESCE(parent_task_id, '')
 			FROM tasks 
 			WHERE status = 'pending'
 		`
@@ -87,9 +125,9 @@ func (q *DbQueue) GetNextTask(ctx context.Context, jobID string) (*Task, error)
 			args = append(args, jobID)
 		}
 
-		// Add ordering and locking
+		// Add ordering and locking. Higher sitemap priority pages are warmed first.
 		query += `
-			ORDER BY created_at ASC
+			ORDER BY priority DESC, created_at ASC
 			LIMIT 1
 			FOR UPDATE SKIP LOCKED
 		`
@@ -105,6 +143,7 @@ func (q *DbQueue) GetNextTask(ctx context.Context, jobID string) (*Task, error)
 		err := row.Scan(
 			&task.ID, &task.JobID, &task.PageID, &task.Path,
 			&task.CreatedAt, &task.RetryCount, &task.SourceType, &task.SourceURL,
+			&task.Depth, &task.ParentTaskID,
 		)
 
 		if err == sql.ErrNoRows {
@@ -142,8 +181,9 @@ func (q *DbQueue) GetNextTask(ctx context.Context, jobID string) (*Task, error)
 	return &task, nil
 }
 
-// EnqueueURLs adds multiple URLs as tasks for a job
-func (q *DbQueue) EnqueueURLs(ctx context.Context, jobID string, pageIDs []int, paths []string, sourceType string, sourceURL string) error {
+// EnqueueURLs adds multiple URLs as tasks for a job. URLs discovered on a task
+// record it as their parent, and depth is their distance in links from the seeds.
+func (q *DbQueue) EnqueueURLs(ctx context.Context, jobID string, pageIDs []int, paths []string, sourceType string, sourceURL string, parentTaskID string, depth int) error {
 	if len(pageIDs) == 0 {
 		return nil
 	}
@@ -159,12 +199,14 @@ func (q *DbQueue) EnqueueURLs(ctx context.Context, jobID string, pageIDs []int,
 			return fmt.Errorf("failed to update job total tasks: %w", err)
 		}
 
-		// Prepare statement for batch insert
+		// Prepare statement for batch insert. Tasks take the page's sitemap
+		// priority, so pages missing from the sitemap come last.
 		stmt, err := tx.PrepareContext(ctx, `
 			INSERT INTO tasks (
 				id, job_id, page_id, path, status, created_at, retry_count,
-				source_type, source_url
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+				source_type, source_url, priority, parent_task_id, depth
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9,
+				COALESCE((SELECT priority FROM pages WHERE id = $3), 0), NULLIF($10, ''), $11)
 		`)
 		if err != nil {
 			return fmt.Errorf("failed to prepare statement: %w", err)
@@ -180,7 +222,7 @@ func (q *DbQueue) EnqueueURLs(ctx context.Context, jobID string, pageIDs []int,
 
 			taskID := uuid.New().String()
 			_, err = stmt.ExecContext(ctx,
-				taskID, jobID, pageID, paths[i], "pending", now, 0, sourceType, sourceURL)
+				taskID, jobID, pageID, paths[i], "pending", now, 0, sourceType, sourceURL, parentTaskID, depth)
 
 			if err != nil {
 				return fmt.Errorf("failed to insert task: %w", err)
@@ -193,7 +235,7 @@ func (q *DbQueue) EnqueueURLs(ctx context.Context, jobID string, pageIDs []int,
 
 // EnqueueTasks is an alias for EnqueueURLs to maintain compatibility with existing code
 func (q *DbQueue) EnqueueTasks(ctx context.Context, jobID string, pageIDs []int, paths []string, sourceType string, sourceURL string, _ int) error {	
-	return q.EnqueueURLs(ctx, jobID, pageIDs, paths, sourceType, sourceURL)
+	return q.EnqueueURLs(ctx, jobID, pageIDs, paths, sourceType, sourceURL, "", 0)
 }
 
 // NewTaskQueue creates a task queue using the provided database connection
@@ -229,23 +271,27 @@ func (q *DbQueue) UpdateJobProgress(ctx context.Context, jobID string) error {
 	defer tx.Rollback()
 
 	// Get counts: use jobs.total_tasks and task statuses
-	var totalTasks, compCount, failCount int
+	var totalTasks, compCount, failCount, skipCount, warmedCount int
+	var totalBytes int64
 	if err := tx.QueryRowContext(ctx, `
 		SELECT j.total_tasks,
 			   COUNT(*) FILTER (WHERE t.status = 'completed'),
-			   COUNT(*) FILTER (WHERE t.status = 'failed')
+			   COUNT(*) FILTER (WHERE t.status = 'failed'),
+			   COUNT(*) FILTER (WHERE t.status = 'skipped'),
+			   COUNT(*) FILTER (WHERE t.status = 'completed' AND t.cache_verified),
+			   COALESCE(SUM(t.bytes_transferred), 0)
 		FROM jobs j
 		LEFT JOIN tasks t ON t.job_id = j.id
 		WHERE j.id = $1
 		GROUP BY j.total_tasks
-	`, jobID).Scan(&totalTasks, &compCount, &failCount); err != nil {
+	`, jobID).Scan(&totalTasks, &compCount, &failCount, &skipCount, &warmedCount, &totalBytes); err != nil {
 		return fmt.Errorf("failed to get job counts: %w", err)
 	}
 
 	// Calculate progress percentage
 	var progress float64 = 0.0
 	if totalTasks > 0 {
-		progress = float64(compCount+failCount) / float64(totalTasks) * 100.0
+		progress = float64(compCount+failCount+skipCount) / float64(totalTasks) * 100.0
 	}
 
 	_, err = tx.ExecContext(ctx, `
@@ -254,16 +300,19 @@ func (q *DbQueue) UpdateJobProgress(ctx context.Context, jobID string) error {
 			progress = $1::REAL,
 			completed_tasks = $2,
 			failed_tasks = $3,
+			skipped_tasks = $4,
+			warmed_tasks = $5,
+			total_bytes = $6,
 			status = CASE 
-				WHEN $1::REAL >= 100.0 THEN 'completed'
+				WHEN $1::REAL >= 100.0 AND status <> 'cancelled' THEN 'completed'
 				ELSE status
 			END,
 			completed_at = CASE 
-				WHEN $1::REAL >= 100.0 THEN NOW()
+				WHEN $1::REAL >= 100.0 AND status <> 'cancelled' THEN NOW()
 				ELSE completed_at
 			END
-		WHERE id = $4
-	`, progress, compCount, failCount, jobID)
+		WHERE id = $7
+	`, progress, compCount, failCount, skipCount, warmedCount, totalBytes, jobID)
 
 	if err != nil {
 		return fmt.Errorf("failed to update job progress: %w", err)
@@ -297,7 +346,7 @@ func (q *DbQueue) CleanupStuckJobs(ctx context.Context) error {
 			progress = 100.0
 		WHERE (status = $3 OR status = $4)
 		AND total_tasks > 0 
-		AND total_tasks = completed_tasks + failed_tasks
+		AND total_tasks = completed_tasks + failed_tasks + skipped_tasks
 	`, JobStatusCompleted, time.Now(), JobStatusPending, JobStatusRunning)
 
 	if err != nil {
@@ -329,10 +378,22 @@ func (q *DbQueue) UpdateTaskStatus(ctx context.Context, task *Task) error {
 	if task.Status == "running" && task.StartedAt.IsZero() {
 		task.StartedAt = now
 	}
-	if (task.Status == "completed" || task.Status == "failed") && task.CompletedAt.IsZero() {
+	if (task.Status == "completed" || task.Status == "failed" || task.Status == "skipped") && task.CompletedAt.IsZero() {
 		task.CompletedAt = now
 	}
 
+	// Store per-attempt errors as JSON, leaving the column NULL when there were none
+	var attemptErrors interface{}
+	if len(task.AttemptErrors) > 0 {
+		attemptErrors = Serialize(task.AttemptErrors)
+	}
+
+	// A negative Content-Length means the server didn't send one
+	var contentLength interface{}
+	if task.ContentLength >= 0 {
+		contentLength = task.ContentLength
+	}
+
 	// Update task in a transaction
 	err := q.Execute(ctx, func(tx *sql.Tx) error {
 		var err error
@@ -350,24 +411,40 @@ func (q *DbQueue) UpdateTaskStatus(ctx context.Context, task *Task) error {
 			_, err = tx.ExecContext(ctx, `
 				UPDATE tasks 
 				SET status = $1, completed_at = $2, status_code = $3, 
-					response_time = $4, cache_status = $5, content_type = $6
-				WHERE id = $7
+					response_time = $4, cache_status = $5, content_type = $6,
+					first_cache_status = $7, cache_attempts = $8, cache_verified = $9,
+					cache_status_raw = $10, cdn = $11, attempts = $12, attempt_errors = $13,
+					warning = NULLIF($14, ''), warning_type = NULLIF($15, ''),
+					bytes_transferred = $16, content_length = $17, body_truncated = $18,
+					dns_lookup_time = $19, tcp_connect_time = $20, tls_handshake_time = $21,
+					ttfb = $22, download_time = $23, conn_reused = $24,
+					canonical_url = NULLIF($25, ''), variant_failures = NULLIF($26, '')
+				WHERE id = $27
 			`, task.Status, task.CompletedAt, task.StatusCode,
-				task.ResponseTime, task.CacheStatus, task.ContentType, task.ID)
+				task.ResponseTime, task.CacheStatus, task.ContentType,
+				task.FirstCacheStatus, task.CacheAttempts, task.CacheVerified,
+				task.CacheStatusRaw, task.CDN, task.Attempts, attemptErrors,
+				task.Warning, task.WarningType,
+				task.BytesTransferred, contentLength, task.BodyTruncated,
+				task.DNSLookupTime, task.TCPConnectTime, task.TLSHandshakeTime,
+				task.TTFB, task.DownloadTime, task.ConnReused, task.CanonicalURL,
+				task.VariantFailures, task.ID)
 
 		case "failed":
 			_, err = tx.ExecContext(ctx, `
 				UPDATE tasks 
-				SET status = $1, completed_at = $2, error = $3, retry_count = $4
-				WHERE id = $5
-			`, task.Status, task.CompletedAt, task.Error, task.RetryCount, task.ID)
+				SET status = $1, completed_at = $2, error = $3, retry_count = $4,
+					attempts = $5, attempt_errors = $6, bytes_transferred = $7
+				WHERE id = $8
+			`, task.Status, task.CompletedAt, task.Error, task.RetryCount,
+				task.Attempts, attemptErrors, task.BytesTransferred, task.ID)
 
 		case "skipped":
 			_, err = tx.ExecContext(ctx, `
 				UPDATE tasks 
-				SET status = $1
-				WHERE id = $2
-			`, task.Status, task.ID)
+				SET status = $1, completed_at = $2, skip_reason = NULLIF($3, '')
+				WHERE id = $4
+			`, task.Status, task.CompletedAt, task.SkipReason, task.ID)
 
 		default:
 			// Generic status update
@@ -390,9 +467,65 @@ func (q *DbQueue) UpdateTaskStatus(ctx context.Context, task *Task) error {
 	}
 
 	// Update job progress if needed
-	if task.Status == "completed" || task.Status == "failed" {
+	if task.Status == "completed" || task.Status == "failed" || task.Status == "skipped" {
 		return q.UpdateJobProgress(ctx, task.JobID)
 	}
 
 	return nil
 }
+
+// TaskResult is the outcome of warming a task's URL with one request variant at one edge
+type TaskResult struct {
+	TaskID           string
+	JobID            string
+	Variant          string
+	Edge             string
+	StatusCode       int
+	ResponseTime     int64
+	CacheStatus      string
+	FirstCacheStatus string
+	CacheVerified    bool
+	BytesTransferred int64
+	Error            string
+}
+
+// SaveTaskResults stores per-variant and per-edge results, replacing any from an earlier attempt at the task
+func (q *DbQueue) SaveTaskResults(ctx context.Context, results []TaskResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	return q.Execute(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `
+			INSERT INTO task_results (
+				task_id, job_id, variant, edge, status_code, response_time, cache_status,
+				first_cache_status, cache_verified, bytes_transferred, error, created_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12)
+			ON CONFLICT (task_id, variant, edge) DO UPDATE SET
+				status_code = EXCLUDED.status_code,
+				response_time = EXCLUDED.response_time,
+				cache_status = EXCLUDED.cache_status,
+				first_cache_status = EXCLUDED.first_cache_status,
+				cache_verified = EXCLUDED.cache_verified,
+				bytes_transferred = EXCLUDED.bytes_transferred,
+				error = EXCLUDED.error,
+				created_at = EXCLUDED.created_at
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		defer stmt.Close()
+
+		now := time.Now()
+		for _, r := range results {
+			_, err = stmt.ExecContext(ctx,
+				r.TaskID, r.JobID, r.Variant, r.Edge, r.StatusCode, r.ResponseTime, r.CacheStatus,
+				r.FirstCacheStatus, r.CacheVerified, r.BytesTransferred, r.Error, now)
+			if err != nil {
+				return fmt.Errorf("failed to save task result: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
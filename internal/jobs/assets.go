@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"net/url"
+	"strings"
+)
+
+// filterAssets keeps the assets served from the job's domain, its subdomains
+// or one of the job's asset hosts
+func filterAssets(assets []string, domain string, assetHosts []string) []string {
+	var filtered []string
+	for _, asset := range assets {
+		u, err := url.Parse(asset)
+		if err != nil {
+			continue
+		}
+		host := strings.ToLower(u.Hostname())
+		allowed := isSameOrSubDomain(host, domain)
+		for _, assetHost := range assetHosts {
+			if allowed {
+				break
+			}
+			allowed = isSameOrSubDomain(host, strings.ToLower(assetHost))
+		}
+		if allowed {
+			filtered = append(filtered, asset)
+		}
+	}
+	return filtered
+}
+
+// newAssets returns the assets not yet seen for a job and marks them seen, so
+// assets shared by many pages are only enqueued once
+func (wp *WorkerPool) newAssets(jobID string, assets []string) []string {
+	wp.assetsMutex.Lock()
+	defer wp.assetsMutex.Unlock()
+
+	seen, ok := wp.assets[jobID]
+	if !ok {
+		seen = make(map[string]bool)
+		wp.assets[jobID] = seen
+	}
+	var unseen []string
+	for _, asset := range assets {
+		if !seen[asset] {
+			seen[asset] = true
+			unseen = append(unseen, asset)
+		}
+	}
+	return unseen
+}
+
+// forgetAssets drops the assets seen for a job
+func (wp *WorkerPool) forgetAssets(jobID string) {
+	wp.assetsMutex.Lock()
+	delete(wp.assets, jobID)
+	wp.assetsMutex.Unlock()
+}
@@ -0,0 +1,48 @@
+package jobs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterAssets(t *testing.T) {
+	assets := []string{
+		"https://example.com/css/site.css",
+		"https://www.example.com/js/app.js",
+		"https://static.example.com/img/logo.png",
+		"https://cdn.example.net/fonts/inter.woff2",
+		"https://images.ctfassets.net/hero.jpg",
+		"https://tracker.example.org/pixel.gif",
+	}
+	got := filterAssets(assets, "example.com", []string{"ctfassets.net"})
+	want := []string{
+		"https://example.com/css/site.css",
+		"https://www.example.com/js/app.js",
+		"https://static.example.com/img/logo.png",
+		"https://images.ctfassets.net/hero.jpg",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filterAssets = %v, want %v", got, want)
+	}
+}
+
+func TestNewAssets(t *testing.T) {
+	wp := &WorkerPool{assets: make(map[string]map[string]bool)}
+
+	first := wp.newAssets("job-1", []string{"https://example.com/a.css", "https://example.com/b.js"})
+	if len(first) != 2 {
+		t.Fatalf("first page assets = %v, want both", first)
+	}
+	second := wp.newAssets("job-1", []string{"https://example.com/a.css", "https://example.com/c.png"})
+	if !reflect.DeepEqual(second, []string{"https://example.com/c.png"}) {
+		t.Errorf("second page assets = %v, want only the unseen asset", second)
+	}
+	if other := wp.newAssets("job-2", []string{"https://example.com/a.css"}); len(other) != 1 {
+		t.Errorf("other job assets = %v, want the asset again", other)
+	}
+
+	wp.forgetAssets("job-1")
+	if again := wp.newAssets("job-1", []string{"https://example.com/a.css"}); len(again) != 1 {
+		t.Errorf("assets after forgetting job = %v, want the asset again", again)
+	}
+}
@@ -0,0 +1,33 @@
+package jobs
+
+import (
+	"net/http"
+
+	"github.com/Harvey-AU/blue-banded-bee/internal/crawler"
+)
+
+// cookieJarFor returns the cookie jar of a task's job, seeded with the job's
+// auth cookies on first use, so cookies the site sets are kept for the rest
+// of the job. Jobs without auth have no jar.
+func (wp *WorkerPool) cookieJarFor(task *Task) http.CookieJar {
+	if task.Auth.IsEmpty() {
+		return nil
+	}
+
+	wp.jarsMutex.Lock()
+	defer wp.jarsMutex.Unlock()
+
+	jar, ok := wp.jars[task.JobID]
+	if !ok {
+		jar = crawler.NewCookieJar(task.Auth)
+		wp.jars[task.JobID] = jar
+	}
+	return jar
+}
+
+// forgetCookies drops the cookie jar of a job
+func (wp *WorkerPool) forgetCookies(jobID string) {
+	wp.jarsMutex.Lock()
+	delete(wp.jars, jobID)
+	wp.jarsMutex.Unlock()
+}
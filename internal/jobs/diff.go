@@ -0,0 +1,198 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrNoPreviousJob is returned when a job is diffed with a predecessor it doesn't have
+var ErrNoPreviousJob = errors.New("no previous job for the domain")
+
+// JobDiff lists the paths a job found that its comparison job didn't, and
+// the reverse, for one source
+type JobDiff struct {
+	JobID         string   `json:"job_id"`
+	PreviousJobID string   `json:"previous_job_id"`
+	SourceType    string   `json:"source_type"`
+	Added         []string `json:"added"`
+	Removed       []string `json:"removed"`
+}
+
+// recordJobURLs stores the paths a job found from a source, so later jobs can
+// be compared with it
+func (jm *JobManager) recordJobURLs(ctx context.Context, jobID, sourceType string, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	return jm.dbQueue.Execute(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO job_urls (job_id, source_type, path)
+			SELECT $1, $2, unnest($3::text[])
+			ON CONFLICT DO NOTHING
+		`, jobID, sourceType, pq.Array(paths))
+		return err
+	})
+}
+
+// previousJobID returns the latest completed job created before this one for the
+// same domain that recorded paths from the source, or an empty string when there
+// is none. Failed and cancelled jobs, and jobs from before paths were recorded,
+// would make every path look added.
+func (jm *JobManager) previousJobID(ctx context.Context, jobID, sourceType string) (string, error) {
+	var previousID string
+	err := jm.dbQueue.Execute(ctx, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, `
+			SELECT p.id
+			FROM jobs j
+			JOIN jobs p ON p.domain_id = j.domain_id AND p.created_at < j.created_at
+			WHERE j.id = $1
+			AND p.status = $2
+			AND EXISTS (
+				SELECT 1 FROM job_urls u WHERE u.job_id = p.id AND u.source_type = $3
+			)
+			ORDER BY p.created_at DESC
+			LIMIT 1
+		`, jobID, string(JobStatusCompleted), sourceType).Scan(&previousID)
+	})
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return previousID, err
+}
+
+// jobURLSet returns the paths a job found from a source
+func (jm *JobManager) jobURLSet(ctx context.Context, jobID, sourceType string) (map[string]bool, error) {
+	paths := make(map[string]bool)
+	err := jm.dbQueue.Execute(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+			SELECT path FROM job_urls WHERE job_id = $1 AND source_type = $2
+		`, jobID, sourceType)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var path string
+			if err := rows.Scan(&path); err != nil {
+				return err
+			}
+			paths[path] = true
+		}
+		return rows.Err()
+	})
+	return paths, err
+}
+
+// DiffJobs compares the paths two jobs found from a source. Without a
+// previous job ID the job is compared with the latest completed job for the
+// same domain that found paths from the source.
+func (jm *JobManager) DiffJobs(ctx context.Context, jobID, previousJobID, sourceType string) (*JobDiff, error) {
+	span := sentry.StartSpan(ctx, "jobs.diff_jobs")
+	defer span.Finish()
+
+	span.SetTag("job_id", jobID)
+
+	if sourceType == "" {
+		sourceType = "sitemap"
+	}
+	if previousJobID == "" {
+		var err error
+		previousJobID, err = jm.previousJobID(ctx, jobID, sourceType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find previous job: %w", err)
+		}
+		if previousJobID == "" {
+			return nil, fmt.Errorf("job %s: %w", jobID, ErrNoPreviousJob)
+		}
+	}
+
+	diff := &JobDiff{
+		JobID:         jobID,
+		PreviousJobID: previousJobID,
+		SourceType:    sourceType,
+		Added:         []string{},
+		Removed:       []string{},
+	}
+	err := jm.dbQueue.Execute(ctx, func(tx *sql.Tx) error {
+		for _, side := range []struct {
+			from, to string
+			paths    *[]string
+		}{
+			{jobID, previousJobID, &diff.Added},
+			{previousJobID, jobID, &diff.Removed},
+		} {
+			rows, err := tx.QueryContext(ctx, `
+				SELECT path FROM job_urls WHERE job_id = $1 AND source_type = $3
+				EXCEPT
+				SELECT path FROM job_urls WHERE job_id = $2 AND source_type = $3
+				ORDER BY path
+			`, side.from, side.to, sourceType)
+			if err != nil {
+				return err
+			}
+			for rows.Next() {
+				var path string
+				if err := rows.Scan(&path); err != nil {
+					rows.Close()
+					return err
+				}
+				*side.paths = append(*side.paths, path)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		span.SetTag("error", "true")
+		span.SetData("error.message", err.Error())
+		return nil, fmt.Errorf("failed to diff jobs: %w", err)
+	}
+
+	log.Debug().
+		Str("job_id", jobID).
+		Str("previous_job_id", previousJobID).
+		Str("source_type", sourceType).
+		Int("added", len(diff.Added)).
+		Int("removed", len(diff.Removed)).
+		Msg("Diffed job URLs")
+
+	return diff, nil
+}
+
+// addedURLs keeps the sitemap URLs whose paths the domain's previous job
+// didn't find. All URLs are kept when there is no previous job.
+func (jm *JobManager) addedURLs(ctx context.Context, jobID, domain string, urls []string) ([]string, error) {
+	previousID, err := jm.previousJobID(ctx, jobID, "sitemap")
+	if err != nil || previousID == "" {
+		return urls, err
+	}
+	previous, err := jm.jobURLSet(ctx, previousID, "sitemap")
+	if err != nil {
+		return urls, err
+	}
+
+	var added []string
+	for _, u := range urls {
+		if !previous[pagePath(domain, u)] {
+			added = append(added, u)
+		}
+	}
+
+	log.Info().
+		Str("job_id", jobID).
+		Str("previous_job_id", previousID).
+		Int("url_count", len(urls)).
+		Int("added_count", len(added)).
+		Msg("Kept only URLs added since previous job")
+
+	return added, nil
+}
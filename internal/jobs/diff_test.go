@@ -0,0 +1,143 @@
+package jobs
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeJob is a job for one domain, in the order jobs were created
+type fakeJob struct {
+	id     string
+	status JobStatus
+	paths  []string // Paths recorded from sitemaps
+}
+
+// diffQueue answers the queries diff.go makes from jobs for a single domain
+func diffQueue(jobs []fakeJob) *fakeQueue {
+	byID := make(map[string]fakeJob)
+	for _, job := range jobs {
+		byID[job.id] = job
+	}
+	paths := func(jobID string, sourceType driver.Value) []string {
+		if sourceType != "sitemap" {
+			return nil
+		}
+		return byID[jobID].paths
+	}
+
+	return &fakeQueue{query: func(query string, args []driver.Value) ([][]driver.Value, error) {
+		var rows [][]driver.Value
+		switch {
+		case strings.Contains(query, "JOIN jobs p"):
+			for i := len(jobs) - 1; i >= 0; i-- {
+				if jobs[i].id != args[0] {
+					continue
+				}
+				for _, p := range slices.Backward(jobs[:i]) {
+					if string(p.status) == args[1] && len(paths(p.id, args[2])) > 0 {
+						return [][]driver.Value{{p.id}}, nil
+					}
+				}
+			}
+		case strings.Contains(query, "EXCEPT"):
+			to := paths(args[1].(string), args[2])
+			for _, path := range paths(args[0].(string), args[2]) {
+				if !slices.Contains(to, path) {
+					rows = append(rows, []driver.Value{path})
+				}
+			}
+		case strings.Contains(query, "SELECT path FROM job_urls"):
+			for _, path := range paths(args[0].(string), args[1]) {
+				rows = append(rows, []driver.Value{path})
+			}
+		default:
+			return nil, errors.New("unexpected query: " + query)
+		}
+		return rows, nil
+	}}
+}
+
+var diffJobs = []fakeJob{
+	{"job-1", JobStatusCompleted, []string{"/a", "/b"}},
+	{"job-2", JobStatusCompleted, nil},
+	{"job-3", JobStatusFailed, []string{"/x"}},
+	{"job-4", JobStatusCancelled, []string{"/y"}},
+	{"job-5", JobStatusRunning, []string{"/b", "/c"}},
+}
+
+func TestPreviousJobID(t *testing.T) {
+	jm := NewJobManager(nil, diffQueue(diffJobs), nil, nil)
+	ctx := context.Background()
+
+	// Jobs that failed, were cancelled or recorded no sitemap paths are skipped
+	if got, err := jm.previousJobID(ctx, "job-5", "sitemap"); err != nil || got != "job-1" {
+		t.Errorf("previousJobID(job-5) = %q, %v, want job-1", got, err)
+	}
+	if got, err := jm.previousJobID(ctx, "job-1", "sitemap"); err != nil || got != "" {
+		t.Errorf("previousJobID(job-1) = %q, %v, want none", got, err)
+	}
+	if got, err := jm.previousJobID(ctx, "job-5", "link"); err != nil || got != "" {
+		t.Errorf("previousJobID(job-5, link) = %q, %v, want none", got, err)
+	}
+}
+
+func TestDiffJobs(t *testing.T) {
+	jm := NewJobManager(nil, diffQueue(diffJobs), nil, nil)
+	ctx := context.Background()
+
+	diff, err := jm.DiffJobs(ctx, "job-5", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &JobDiff{
+		JobID:         "job-5",
+		PreviousJobID: "job-1",
+		SourceType:    "sitemap",
+		Added:         []string{"/c"},
+		Removed:       []string{"/a"},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("DiffJobs = %+v, want %+v", diff, want)
+	}
+
+	// An explicit comparison job is used as given
+	diff, err = jm.DiffJobs(ctx, "job-5", "job-3", "sitemap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(diff.Added, []string{"/b", "/c"}) || !reflect.DeepEqual(diff.Removed, []string{"/x"}) {
+		t.Errorf("DiffJobs with job-3 = %+v", diff)
+	}
+
+	if _, err := jm.DiffJobs(ctx, "job-1", "", ""); !errors.Is(err, ErrNoPreviousJob) {
+		t.Errorf("DiffJobs(job-1) error = %v, want ErrNoPreviousJob", err)
+	}
+}
+
+func TestAddedURLs(t *testing.T) {
+	jm := NewJobManager(nil, diffQueue(diffJobs), nil, nil)
+	ctx := context.Background()
+	urls := []string{"https://example.com/b", "https://www.example.com/c", "https://example.com/d"}
+
+	added, err := jm.addedURLs(ctx, "job-5", "example.com", urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"https://www.example.com/c", "https://example.com/d"}; !reflect.DeepEqual(added, want) {
+		t.Errorf("addedURLs = %v, want %v", added, want)
+	}
+
+	// Every URL is kept when there is no previous job
+	added, err = jm.addedURLs(ctx, "job-1", "example.com", urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(added, urls) {
+		t.Errorf("addedURLs without a previous job = %v, want %v", added, urls)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -19,7 +20,7 @@ import (
 // DbQueueProvider defines the interface for database operations
 type DbQueueProvider interface {
 	Execute(ctx context.Context, fn func(*sql.Tx) error) error
-	EnqueueURLs(ctx context.Context, jobID string, pageIDs []int, paths []string, sourceType string, sourceURL string) error
+	EnqueueURLs(ctx context.Context, jobID string, pageIDs []int, paths []string, sourceType string, sourceURL string, parentTaskID string, depth int) error
 	CleanupStuckJobs(ctx context.Context) error
 }
 
@@ -54,28 +55,106 @@ func (jm *JobManager) CreateJob(ctx context.Context, options *JobOptions) (*Job,
 
 	span.SetTag("domain", options.Domain)
 
+	if err := validateVariants(options.Variants); err != nil {
+		return nil, fmt.Errorf("invalid variants: %w", err)
+	}
+	for _, edge := range options.Edges {
+		if err := crawler.ValidateEdge(edge); err != nil {
+			return nil, fmt.Errorf("invalid edges: %w", err)
+		}
+	}
+	if options.MaxDepth < 0 {
+		return nil, fmt.Errorf("invalid max depth: %d", options.MaxDepth)
+	}
+	if !crawler.ValidTrailingSlash(options.URLPolicy.TrailingSlash) {
+		return nil, fmt.Errorf("invalid trailing slash policy: %s", options.URLPolicy.TrailingSlash)
+	}
+	if _, err := crawler.CompileURLRules(options.IncludePaths, options.ExcludePaths, options.RulePrecedence); err != nil {
+		return nil, fmt.Errorf("invalid include/exclude rules: %w", err)
+	}
+
 	// Normalize domain to ensure consistent handling of www. prefix and http/https
 	normalizedDomain := strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(options.Domain, "http://"), "https://"), "www.")
 	normalizedDomain = strings.TrimSuffix(normalizedDomain, "/")
 	
 	// Create a new job object
 	job := &Job{
-		ID:              uuid.New().String(),
-		Domain:          normalizedDomain, // Use normalized domain
-		Status:          JobStatusPending,
-		Progress:        0,
-		TotalTasks:      0,
-		CompletedTasks:  0,
-		FoundTasks:      0,
-		SitemapTasks:    0,
-		FailedTasks:     0,
-		CreatedAt:       time.Now(),
-		Concurrency:     options.Concurrency,
-		FindLinks:       options.FindLinks,
-		MaxPages:        options.MaxPages,
-		IncludePaths:    options.IncludePaths,
-		ExcludePaths:    options.ExcludePaths,
-		RequiredWorkers: options.RequiredWorkers,
+		ID:               uuid.New().String(),
+		Domain:           normalizedDomain, // Use normalized domain
+		Status:           JobStatusPending,
+		Progress:         0,
+		TotalTasks:       0,
+		CompletedTasks:   0,
+		FoundTasks:       0,
+		SitemapTasks:     0,
+		FailedTasks:      0,
+		CreatedAt:        time.Now(),
+		Concurrency:      options.Concurrency,
+		FindLinks:        options.FindLinks,
+		MaxPages:         options.MaxPages,
+		IncludePaths:     options.IncludePaths,
+		ExcludePaths:     options.ExcludePaths,
+		RequiredWorkers:  options.RequiredWorkers,
+		VerifyCache:      options.VerifyCache,
+		VerifyAttempts:   options.VerifyAttempts,
+		VerifyDelayMs:    options.VerifyDelayMs,
+		MaxBodySize:      options.MaxBodySize,
+		Variants:         options.Variants,
+		Edges:            options.Edges,
+		DNSServers:       options.DNSServers,
+		ModifiedSince:    options.ModifiedSince,
+		MaxSitemapURLs:   options.MaxSitemapURLs,
+		FeedURLs:         options.FeedURLs,
+		IncludeHreflang:  options.IncludeHreflang,
+		IncludeImages:    options.IncludeImages,
+		IncludeVideos:    options.IncludeVideos,
+		RespectRobots:    options.RespectRobots,
+		SitemapURLs:      options.SitemapURLs,
+		OnlyAddedURLs:    options.OnlyAddedURLs,
+		FindAssets:       options.FindAssets,
+		AssetHosts:       options.AssetHosts,
+		URLPolicy:        options.URLPolicy,
+		RespectNofollow:  options.RespectNofollow,
+		EnqueueCanonical: options.EnqueueCanonical,
+		MaxDepth:         options.MaxDepth,
+		RulePrecedence:   options.RulePrecedence,
+	}
+
+	// Credentials are encrypted before they are stored, and only sent to the job's domain
+	var encryptedAuth interface{}
+	if !options.Auth.IsEmpty() {
+		auth := *options.Auth
+		if auth.Domain == "" {
+			auth.Domain = normalizedDomain
+		}
+		secret, err := auth.MarshalSecret()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode auth: %w", err)
+		}
+		encryptedAuth, err = db.EncryptSecret(secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt auth: %w", err)
+		}
+		job.Auth = &auth
+	}
+
+	// Resolve the host requests will go to, which may be the www. host, against
+	// each DNS server to find edges beyond the nearest one
+	if len(options.DNSServers) > 0 {
+		baseURL, err := jm.crawler.SiteBaseURL(ctx, normalizedDomain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the site's host: %w", err)
+		}
+		base, err := url.Parse(baseURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the site's base URL: %w", err)
+		}
+		resolved, err := jm.crawler.ResolveEdges(ctx, base.Hostname(), options.DNSServers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve edges: %w", err)
+		}
+		job.Edges = mergeEdges(job.Edges, resolved)
+		job.BaseURL = baseURL
 	}
 
 	var domainID int
@@ -91,20 +170,48 @@ func (jm *JobManager) CreateJob(ctx context.Context, options *JobOptions) (*Job,
 			return fmt.Errorf("failed to get or create domain: %w", err)
 		}
 
+		// Pages changed since the last completed job was created still need warming
+		if options.ModifiedSinceLastJob {
+			var lastJobCreated sql.NullTime
+			err = tx.QueryRow(`
+				SELECT MAX(created_at) FROM jobs
+				WHERE domain_id = $1 AND status = $2`, domainID, string(JobStatusCompleted)).Scan(&lastJobCreated)
+			if err != nil {
+				return fmt.Errorf("failed to find last completed job: %w", err)
+			}
+			if lastJobCreated.Valid {
+				job.ModifiedSince = &lastJobCreated.Time
+			}
+		}
+
 		// Insert the job
 		_, err = tx.Exec(
 			`INSERT INTO jobs (
 				id, domain_id, status, progress, total_tasks, completed_tasks, failed_tasks,
 				created_at, concurrency, find_links, include_paths, exclude_paths,
 				required_workers, max_pages,
-				found_tasks, sitemap_tasks
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`,
+				found_tasks, sitemap_tasks,
+				verify_cache, verify_attempts, verify_delay_ms,
+				max_body_size, variants, edges, dns_servers, auth,
+				modified_since, max_sitemap_urls, feed_urls,
+				include_hreflang, include_images, include_videos, respect_robots,
+				sitemap_urls, only_added_urls, find_assets, asset_hosts, url_policy,
+				respect_nofollow, enqueue_canonical, max_depth, rule_precedence, base_url
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35, $36, $37, $38, $39, $40, NULLIF($41, ''))`,
 			job.ID, domainID, string(job.Status), job.Progress,
 			job.TotalTasks, job.CompletedTasks, job.FailedTasks,
 			job.CreatedAt, job.Concurrency, job.FindLinks,
 			db.Serialize(job.IncludePaths), db.Serialize(job.ExcludePaths),
 			job.RequiredWorkers, job.MaxPages,
 			job.FoundTasks, job.SitemapTasks,
+			job.VerifyCache, job.VerifyAttempts, job.VerifyDelayMs,
+			job.MaxBodySize, serializeVariants(job.Variants),
+			db.Serialize(job.Edges), db.Serialize(job.DNSServers), encryptedAuth,
+			job.ModifiedSince, job.MaxSitemapURLs, db.Serialize(job.FeedURLs),
+			job.IncludeHreflang, job.IncludeImages, job.IncludeVideos, job.RespectRobots,
+			db.Serialize(job.SitemapURLs), job.OnlyAddedURLs, job.FindAssets, db.Serialize(job.AssetHosts),
+			db.Serialize(job.URLPolicy), job.RespectNofollow, job.EnqueueCanonical, job.MaxDepth,
+			job.RulePrecedence, job.BaseURL,
 		)
 		return err
 	})
@@ -121,12 +228,13 @@ func (jm *JobManager) CreateJob(ctx context.Context, options *JobOptions) (*Job,
 		Bool("use_sitemap", options.UseSitemap).
 		Bool("find_links", options.FindLinks).
 		Int("max_pages", options.MaxPages).
+		Bool("verify_cache", options.VerifyCache).
 		Msg("Created new job")
 
-	if options.UseSitemap {
+	if options.UseSitemap || len(options.FeedURLs) > 0 || len(options.SitemapURLs) > 0 {
 		// Fetch and process sitemap in a separate goroutine
-		go jm.processSitemap(context.Background(), job.ID, normalizedDomain, options.IncludePaths, options.ExcludePaths)
-	} else {
+		go jm.processSitemap(context.Background(), job)
+	} else if !jm.rootExcluded(ctx, job) {
 		// Prepare for manual root URL creation
 		rootPath := "/"
 		
@@ -301,7 +409,7 @@ func (jm *JobManager) clearProcessedPages(jobID string) {
 }
 
 // EnqueueJobURLs is a wrapper around dbQueue.EnqueueURLs that adds duplicate detection
-func (jm *JobManager) EnqueueJobURLs(ctx context.Context, jobID string, pageIDs []int, paths []string, sourceType string, sourceURL string) error {
+func (jm *JobManager) EnqueueJobURLs(ctx context.Context, jobID string, pageIDs []int, paths []string, sourceType string, sourceURL string, parent *Task) error {
 	span := sentry.StartSpan(ctx, "manager.enqueue_job_urls")
 	defer span.Finish()
 	
@@ -311,6 +419,29 @@ func (jm *JobManager) EnqueueJobURLs(ctx context.Context, jobID string, pageIDs
 	if len(pageIDs) == 0 {
 		return nil
 	}
+
+	// URLs found on a task are a link further from the seeds than it is. Links
+	// past the job's maximum depth, carried by the task's job config, are dropped.
+	parentTaskID, depth := "", 0
+	if parent != nil {
+		parentTaskID, depth = parent.ID, parent.Depth+1
+		if sourceType == "link" && parent.MaxDepth > 0 && depth > parent.MaxDepth {
+			log.Debug().
+				Str("job_id", jobID).
+				Int("depth", depth).
+				Int("max_depth", parent.MaxDepth).
+				Int("skipped_urls", len(pageIDs)).
+				Msg("Links are beyond the job's maximum depth, skipping")
+			return nil
+		}
+	}
+
+	// Sitemap URLs are recorded in full before filtering, other sources as they're found
+	if sourceType != "sitemap" {
+		if err := jm.recordJobURLs(ctx, jobID, sourceType, paths); err != nil {
+			log.Error().Err(err).Str("job_id", jobID).Str("source_type", sourceType).Msg("Failed to record job URLs")
+		}
+	}
 	
 	// Filter out pages that have already been processed
 	var filteredPageIDs []int
@@ -341,12 +472,12 @@ func (jm *JobManager) EnqueueJobURLs(ctx context.Context, jobID string, pageIDs
 		Msg("Enqueueing filtered URLs")
 	
 	// Use the filtered lists to enqueue only new pages
-	err := jm.dbQueue.EnqueueURLs(ctx, jobID, filteredPageIDs, filteredPaths, sourceType, sourceURL)
+	err := jm.dbQueue.EnqueueURLs(ctx, jobID, filteredPageIDs, filteredPaths, sourceType, sourceURL, parentTaskID, depth)
 	
 	// Only mark pages as processed if the enqueue was successful
 	if err == nil {
 		// If these are found links (not from sitemap), update the found_tasks counter
-		if sourceType != "sitemap" && len(filteredPageIDs) > 0 {
+		if !isSitemapSource(sourceType) && len(filteredPageIDs) > 0 {
 			updateErr := jm.dbQueue.Execute(ctx, func(tx *sql.Tx) error {
 				_, err := tx.ExecContext(ctx, `
 					UPDATE jobs
@@ -433,6 +564,7 @@ func (jm *JobManager) CancelJob(ctx context.Context, jobID string) error {
 
 	// Remove job from worker pool
 	jm.workerPool.RemoveJob(job.ID)
+	jm.workerPool.forgetJob(job.ID)
 	
 	// Clear processed pages for this job
 	jm.clearProcessedPages(job.ID)
@@ -453,9 +585,9 @@ func (jm *JobManager) GetJob(ctx context.Context, jobID string) (*Job, error) {
 	span.SetTag("job_id", jobID)
 
 	var job Job
-	var includePaths, excludePaths []byte
-	var startedAt, completedAt sql.NullTime
-	var errorMessage sql.NullString
+	var includePaths, excludePaths, variants, edges, dnsServers, feedURLs, sitemapURLs, redirects, assetHosts, urlPolicy []byte
+	var startedAt, completedAt, modifiedSince sql.NullTime
+	var errorMessage, baseURL, rulePrecedence sql.NullString
 
 	// Use DbQueue.Execute for transactional safety
 	err := jm.dbQueue.Execute(ctx, func(tx *sql.Tx) error {
@@ -465,7 +597,15 @@ func (jm *JobManager) GetJob(ctx context.Context, jobID string) (*Job, error) {
 				j.id, d.name, j.status, j.progress, j.total_tasks, j.completed_tasks, j.failed_tasks,
 				j.created_at, j.started_at, j.completed_at, j.concurrency, j.find_links,
 				j.include_paths, j.exclude_paths, j.error_message, j.required_workers,
-				j.found_tasks, j.sitemap_tasks
+				j.found_tasks, j.sitemap_tasks,
+				j.verify_cache, j.verify_attempts, j.verify_delay_ms, j.warmed_tasks,
+				j.max_body_size, j.total_bytes, j.variants, j.edges, j.dns_servers,
+				j.modified_since, j.max_sitemap_urls, j.feed_urls,
+				j.include_hreflang, j.include_images, j.include_videos,
+				j.respect_robots, j.crawl_delay_ms, j.skipped_tasks,
+				j.sitemap_urls, j.base_url, j.redirects, j.only_added_urls,
+				j.find_assets, j.asset_hosts, j.url_policy,
+				j.respect_nofollow, j.enqueue_canonical, j.max_depth, j.rule_precedence
 			FROM jobs j
 			JOIN domains d ON j.domain_id = d.id
 			WHERE j.id = $1
@@ -474,6 +614,14 @@ func (jm *JobManager) GetJob(ctx context.Context, jobID string) (*Job, error) {
 			&job.FailedTasks, &job.CreatedAt, &startedAt, &completedAt, &job.Concurrency,
 			&job.FindLinks, &includePaths, &excludePaths, &errorMessage, &job.RequiredWorkers,
 			&job.FoundTasks, &job.SitemapTasks,
+			&job.VerifyCache, &job.VerifyAttempts, &job.VerifyDelayMs, &job.WarmedTasks,
+			&job.MaxBodySize, &job.TotalBytes, &variants, &edges, &dnsServers,
+			&modifiedSince, &job.MaxSitemapURLs, &feedURLs,
+			&job.IncludeHreflang, &job.IncludeImages, &job.IncludeVideos,
+			&job.RespectRobots, &job.CrawlDelayMs, &job.SkippedTasks,
+			&sitemapURLs, &baseURL, &redirects, &job.OnlyAddedURLs,
+			&job.FindAssets, &assetHosts, &urlPolicy,
+			&job.RespectNofollow, &job.EnqueueCanonical, &job.MaxDepth, &rulePrecedence,
 		)
 		return err
 	})
@@ -499,6 +647,18 @@ func (jm *JobManager) GetJob(ctx context.Context, jobID string) (*Job, error) {
 		job.ErrorMessage = errorMessage.String
 	}
 
+	if modifiedSince.Valid {
+		job.ModifiedSince = &modifiedSince.Time
+	}
+
+	if baseURL.Valid {
+		job.BaseURL = baseURL.String
+	}
+
+	if rulePrecedence.Valid {
+		job.RulePrecedence = rulePrecedence.String
+	}
+
 	// Parse arrays from JSON
 	if len(includePaths) > 0 {
 		err = json.Unmarshal(includePaths, &job.IncludePaths)
@@ -514,6 +674,62 @@ func (jm *JobManager) GetJob(ctx context.Context, jobID string) (*Job, error) {
 		}
 	}
 
+	if len(variants) > 0 {
+		err = json.Unmarshal(variants, &job.Variants)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal variants: %w", err)
+		}
+	}
+
+	if len(edges) > 0 {
+		err = json.Unmarshal(edges, &job.Edges)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal edges: %w", err)
+		}
+	}
+
+	if len(dnsServers) > 0 {
+		err = json.Unmarshal(dnsServers, &job.DNSServers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal dns servers: %w", err)
+		}
+	}
+
+	if len(feedURLs) > 0 {
+		err = json.Unmarshal(feedURLs, &job.FeedURLs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal feed urls: %w", err)
+		}
+	}
+
+	if len(sitemapURLs) > 0 {
+		err = json.Unmarshal(sitemapURLs, &job.SitemapURLs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal sitemap urls: %w", err)
+		}
+	}
+
+	if len(redirects) > 0 {
+		err = json.Unmarshal(redirects, &job.Redirects)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal redirects: %w", err)
+		}
+	}
+
+	if len(assetHosts) > 0 {
+		err = json.Unmarshal(assetHosts, &job.AssetHosts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal asset hosts: %w", err)
+		}
+	}
+
+	if len(urlPolicy) > 0 {
+		err = json.Unmarshal(urlPolicy, &job.URLPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal url policy: %w", err)
+		}
+	}
+
 	return &job, nil
 }
 
@@ -541,104 +757,200 @@ func (jm *JobManager) GetJobStatus(ctx context.Context, jobID string) (*Job, err
 	return job, nil
 }
 
-// createPageRecords creates page records for a list of URLs and returns their IDs and paths
-func (jm *JobManager) createPageRecords(ctx context.Context, domainID int, urls []string) ([]int, []string, error) {
-	span := sentry.StartSpan(ctx, "manager.create_page_records")
-	defer span.Finish()
+// enqueueSitemapExtensions enqueues the hreflang alternates, images and videos
+// listed for the job's sitemap pages, for the extensions the job includes.
+// Each follows the job's include/exclude rules like pages do.
+func (jm *JobManager) enqueueSitemapExtensions(ctx context.Context, job *Job, rules *crawler.URLRules, domainID int, urls []string, entries map[string]crawler.SitemapEntry, baseURL string) {
+	if !job.IncludeHreflang && !job.IncludeImages && !job.IncludeVideos {
+		return
+	}
 
-	span.SetTag("domain_id", fmt.Sprintf("%d", domainID))
-	span.SetTag("url_count", fmt.Sprintf("%d", len(urls)))
+	// URLs already queued as sitemap pages aren't queued again
+	seen := make(map[string]bool, len(urls))
+	for _, u := range urls {
+		seen[u] = true
+	}
+	collect := func(enabled bool, pick func(crawler.SitemapEntry) []string) []string {
+		if !enabled {
+			return nil
+		}
+		var found []string
+		for _, u := range urls {
+			for _, extra := range pick(entries[u]) {
+				if !seen[extra] {
+					seen[extra] = true
+					found = append(found, extra)
+				}
+			}
+		}
+		return found
+	}
 
-	if len(urls) == 0 {
-		return []int{}, []string{}, nil
+	sources := []struct {
+		sourceType string
+		urls       []string
+	}{
+		{"hreflang", collect(job.IncludeHreflang, func(e crawler.SitemapEntry) []string { return e.Alternates })},
+		{"sitemap_image", collect(job.IncludeImages, func(e crawler.SitemapEntry) []string { return e.Images })},
+		{"sitemap_video", collect(job.IncludeVideos, func(e crawler.SitemapEntry) []string { return e.Videos })},
 	}
 
-	pageIDs := make([]int, 0, len(urls))
-	paths := make([]string, 0, len(urls))
+	for _, source := range sources {
+		source.urls = applyURLRules(ctx, jm.dbQueue, job.ID, job.Domain, rules, source.urls)
+		if len(source.urls) == 0 {
+			continue
+		}
+
+		pages, err := createPageRecords(ctx, jm.dbQueue, domainID, source.urls, job.URLPolicy)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("job_id", job.ID).
+				Str("source_type", source.sourceType).
+				Msg("Failed to create page records")
+			continue
+		}
+		pageIDs, paths := pageIDsAndPaths(pages)
 
-	// Get domain name from the database
-	var domainName string
+		err = jm.dbQueue.Execute(ctx, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, `
+				UPDATE jobs
+				SET sitemap_tasks = sitemap_tasks + $1
+				WHERE id = $2
+			`, len(pageIDs), job.ID)
+			return err
+		})
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("job_id", job.ID).
+				Msg("Failed to update sitemap task count")
+		}
+
+		if err := jm.EnqueueJobURLs(ctx, job.ID, pageIDs, paths, source.sourceType, baseURL, nil); err != nil {
+			log.Error().
+				Err(err).
+				Str("job_id", job.ID).
+				Str("source_type", source.sourceType).
+				Msg("Failed to enqueue sitemap extension URLs")
+			continue
+		}
+
+		log.Info().
+			Str("job_id", job.ID).
+			Str("source_type", source.sourceType).
+			Int("url_count", len(source.urls)).
+			Msg("Added sitemap extension URLs to job queue")
+	}
+}
+
+// isSitemapSource reports whether tasks of the source type came from a sitemap
+func isSitemapSource(sourceType string) bool {
+	switch sourceType {
+	case "sitemap", "hreflang", "sitemap_image", "sitemap_video":
+		return true
+	}
+	return false
+}
+
+// saveSiteDiscovery records the host a job's site is served from and the
+// redirects followed to reach it, so tasks are requested from that host
+func (jm *JobManager) saveSiteDiscovery(ctx context.Context, jobID string, discovery *crawler.SiteDiscovery) {
 	err := jm.dbQueue.Execute(ctx, func(tx *sql.Tx) error {
-		return tx.QueryRowContext(ctx, `
-			SELECT name FROM domains WHERE id = $1
-		`, domainID).Scan(&domainName)
+		_, err := tx.ExecContext(ctx, `
+			UPDATE jobs
+			SET base_url = $1, redirects = $2
+			WHERE id = $3
+		`, discovery.BaseURL, db.Serialize(discovery.Redirects), jobID)
+		return err
 	})
 	if err != nil {
-		span.SetTag("error", "true")
-		span.SetData("error.message", err.Error())
-		return nil, nil, fmt.Errorf("failed to get domain name: %w", err)
+		log.Error().Err(err).Str("job_id", jobID).Msg("Failed to save site discovery")
 	}
+}
 
-	// Extract paths from URLs
-	for _, url := range urls {
-		// Parse URL to extract the path
-		log.Debug().Str("original_url", url).Msg("Processing URL")
+// saveSitemapResults records the outcome of each sitemap read for a job
+func (jm *JobManager) saveSitemapResults(ctx context.Context, jobID string, results []crawler.SitemapResult) error {
+	if len(results) == 0 {
+		return nil
+	}
 
-		// Remove any protocol and domain to get just the path
-		path := url
-		// Strip common prefixes
-		path = strings.TrimPrefix(path, "http://")
-		path = strings.TrimPrefix(path, "https://")
-		path = strings.TrimPrefix(path, "www.")
-		
-		// Find the first slash after the domain name
-		domainEnd := strings.Index(path, "/")
-		if domainEnd != -1 {
-			// Extract just the path part
-			path = path[domainEnd:]
-		} else {
-			// If no path found, use root path
-			path = "/"
+	return jm.dbQueue.Execute(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `
+			INSERT INTO job_sitemaps (
+				job_id, url, parent_url, depth, status_code, format, url_count, child_count, error, created_at
+			) VALUES ($1, $2, NULLIF($3, ''), $4, NULLIF($5, 0), NULLIF($6, ''), $7, $8, NULLIF($9, ''), $10)
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare sitemap result statement: %w", err)
 		}
+		defer stmt.Close()
 
-		// Add paths to our result array
-		paths = append(paths, path)
-		log.Debug().Str("extracted_path", path).Msg("Extracted path from URL")
-	}
+		now := time.Now()
+		for _, r := range results {
+			if _, err := stmt.ExecContext(ctx, jobID, r.URL, r.ParentURL, r.Depth, r.StatusCode, r.Format,
+				r.URLCount, r.ChildCount, r.Error, now); err != nil {
+				return fmt.Errorf("failed to insert sitemap result: %w", err)
+			}
+		}
+		return nil
+	})
+}
 
-	// Insert pages into database in a transaction
-	err = jm.dbQueue.Execute(ctx, func(tx *sql.Tx) error {
-		// Prepare statement for bulk insertion
+// savePageMetadata stores the sitemap lastmod, changefreq and priority of each
+// page, looking each up by the canonical URL its record was created for
+func (jm *JobManager) savePageMetadata(ctx context.Context, pages []pageRecord, entries map[string]crawler.SitemapEntry) error {
+	return jm.dbQueue.Execute(ctx, func(tx *sql.Tx) error {
 		stmt, err := tx.PrepareContext(ctx, `
-			INSERT INTO pages (domain_id, path)
-			VALUES ($1, $2)
-			ON CONFLICT (domain_id, path) DO UPDATE SET path = EXCLUDED.path
-			RETURNING id
+			UPDATE pages
+			SET lastmod = $2, changefreq = NULLIF($3, ''), priority = $4
+			WHERE id = $1
 		`)
 		if err != nil {
-			return fmt.Errorf("failed to prepare page insert statement: %w", err)
+			return fmt.Errorf("failed to prepare page metadata statement: %w", err)
 		}
 		defer stmt.Close()
 
-		// Insert each page and collect the IDs
-		for _, path := range paths {
-			var pageID int
-			err := stmt.QueryRowContext(ctx, domainID, path).Scan(&pageID)
-			if err != nil {
-				return fmt.Errorf("failed to insert page record: %w", err)
+		for _, page := range pages {
+			entry, ok := entries[page.url]
+			if !ok {
+				continue
+			}
+			var lastMod interface{}
+			if !entry.LastMod.IsZero() {
+				lastMod = entry.LastMod
+			}
+			if _, err := stmt.ExecContext(ctx, page.id, lastMod, entry.ChangeFreq, entry.Priority); err != nil {
+				return fmt.Errorf("failed to update page metadata: %w", err)
 			}
-			pageIDs = append(pageIDs, pageID)
 		}
-
 		return nil
 	})
+}
 
-	if err != nil {
-		span.SetTag("error", "true")
-		span.SetData("error.message", err.Error())
-		return nil, nil, fmt.Errorf("failed to create page records: %w", err)
+// jobSitemaps returns the sitemaps a job reads: the sitemaps it names, or else
+// those discovered for the domain, followed by its feeds. The discovery is
+// returned when it ran. Feeds are still read when discovery fails.
+func jobSitemaps(ctx context.Context, c *crawler.Crawler, domain string, sitemapURLs, feedURLs []string) ([]string, *crawler.SiteDiscovery, error) {
+	if len(sitemapURLs) > 0 {
+		return append(append([]string{}, sitemapURLs...), feedURLs...), nil, nil
 	}
 
-	log.Debug().
-		Int("domain_id", domainID).
-		Int("page_count", len(pageIDs)).
-		Msg("Created page records")
-
-	return pageIDs, paths, nil
+	discovery, err := c.DiscoverSite(ctx, domain)
+	if err != nil {
+		if len(feedURLs) == 0 {
+			return nil, nil, err
+		}
+		log.Warn().Err(err).Str("domain", domain).Msg("Failed to discover sitemaps, reading feeds only")
+		return append([]string{}, feedURLs...), nil, nil
+	}
+	return append(append([]string{}, discovery.Sitemaps...), feedURLs...), discovery, nil
 }
 
 // processSitemap fetches and processes a sitemap for a domain
-func (jm *JobManager) processSitemap(ctx context.Context, jobID, domain string, includePaths, excludePaths []string) {
+func (jm *JobManager) processSitemap(ctx context.Context, job *Job) {
+	jobID, domain := job.ID, job.Domain
+
 	span := sentry.StartSpan(ctx, "manager.process_sitemap")
 	defer span.Finish()
 
@@ -653,12 +965,17 @@ func (jm *JobManager) processSitemap(ctx context.Context, jobID, domain string,
 	// Create a crawler config that allows skipping already cached URLs
 	crawlerConfig := crawler.DefaultConfig()
 	crawlerConfig.SkipCachedURLs = false
+	crawlerConfig.Auth = job.Auth
 	sitemapCrawler := crawler.New(crawlerConfig)
+	defer sitemapCrawler.CloseIdleConnections()
 
-	// Discover sitemaps for the domain
+	// Discover sitemaps for the domain, unless the job names its sitemaps
 	// Note: Only pass the domain, not the full URL with https://
-	// as DiscoverSitemaps already adds the https:// prefix
-	sitemaps, err := sitemapCrawler.DiscoverSitemaps(ctx, domain)
+	// as DiscoverSite tries each scheme and host variant itself
+	sitemaps, discovery, err := jobSitemaps(ctx, sitemapCrawler, domain, job.SitemapURLs, job.FeedURLs)
+	if discovery != nil {
+		jm.saveSiteDiscovery(ctx, jobID, discovery)
+	}
 	
 	// Log discovered sitemaps
 	log.Info().
@@ -667,31 +984,58 @@ func (jm *JobManager) processSitemap(ctx context.Context, jobID, domain string,
 		Int("sitemap_count", len(sitemaps)).
 		Msg("Sitemaps discovered")
 		
-	// Process each sitemap to extract URLs
-	var urls []string
-	for _, sitemapURL := range sitemaps {
-		log.Info().
+	// Traverse the sitemaps and their indexes within the job's limits
+	limits := sitemapCrawler.DefaultSitemapLimits()
+	if job.MaxSitemapURLs > 0 {
+		limits.MaxURLs = job.MaxSitemapURLs
+	}
+	sitemapEntries, sitemapResults := sitemapCrawler.TraverseSitemaps(ctx, sitemaps, limits)
+	// Canonicalise before anything is recorded, compared or deduplicated
+	sitemapEntries = job.URLPolicy.CanonicalizeEntries(sitemapEntries)
+	if saveErr := jm.saveSitemapResults(ctx, jobID, sitemapResults); saveErr != nil {
+		log.Error().
+			Err(saveErr).
 			Str("job_id", jobID).
-			Str("sitemap_url", sitemapURL).
-			Msg("Processing sitemap")
-			
-		sitemapURLs, err := sitemapCrawler.ParseSitemap(ctx, sitemapURL)
-		if err != nil {
-			log.Warn().
-				Err(err).
-				Str("job_id", jobID).
-				Str("sitemap_url", sitemapURL).
-				Msg("Error parsing sitemap")
+			Msg("Failed to save sitemap results")
+	}
+
+	log.Info().
+		Str("job_id", jobID).
+		Int("sitemap_count", len(sitemapResults)).
+		Int("url_count", len(sitemapEntries)).
+		Msg("Parsed URLs from sitemaps")
+
+	// Record every sitemap URL, before any filtering, so later jobs can be diffed against it
+	sitemapPaths := make([]string, 0, len(sitemapEntries))
+	for _, entry := range sitemapEntries {
+		sitemapPaths = append(sitemapPaths, pagePath(domain, entry.Loc))
+	}
+	if recordErr := jm.recordJobURLs(ctx, jobID, "sitemap", sitemapPaths); recordErr != nil {
+		log.Error().
+			Err(recordErr).
+			Str("job_id", jobID).
+			Msg("Failed to record sitemap URLs")
+	}
+
+	// Keep each page's sitemap metadata
+	var urls []string
+	entries := make(map[string]crawler.SitemapEntry)
+	notModified := 0
+	for _, entry := range sitemapEntries {
+		// Pages without a lastmod are kept, as they may have changed
+		if job.ModifiedSince != nil && !entry.LastMod.IsZero() && !entry.LastMod.After(*job.ModifiedSince) {
+			notModified++
 			continue
 		}
-		
+		urls = append(urls, entry.Loc)
+		entries[entry.Loc] = entry
+	}
+	if notModified > 0 {
 		log.Info().
 			Str("job_id", jobID).
-			Str("sitemap_url", sitemapURL).
-			Int("url_count", len(sitemapURLs)).
-			Msg("Parsed URLs from sitemap")
-			
-		urls = append(urls, sitemapURLs...)
+			Time("modified_since", *job.ModifiedSince).
+			Int("skipped_count", notModified).
+			Msg("Skipped sitemap URLs not modified since cutoff")
 	}
 	if err != nil {
 		span.SetTag("error", "true")
@@ -716,10 +1060,27 @@ func (jm *JobManager) processSitemap(ctx context.Context, jobID, domain string,
 		return
 	}
 
-	// Filter URLs based on include/exclude patterns
-	if len(includePaths) > 0 || len(excludePaths) > 0 {
-		urls = sitemapCrawler.FilterURLs(urls, includePaths, excludePaths)
+	// Only warm URLs the domain's previous job didn't find
+	if job.OnlyAddedURLs {
+		if added, addedErr := jm.addedURLs(ctx, jobID, domain, urls); addedErr != nil {
+			log.Error().
+				Err(addedErr).
+				Str("job_id", jobID).
+				Msg("Failed to compare with previous job, warming all sitemap URLs")
+		} else {
+			urls = added
+		}
+	}
+
+	// Filter URLs with the job's include/exclude rules, validated when the job was created
+	rules, rulesErr := jobRules(job)
+	if rulesErr != nil {
+		log.Error().
+			Err(rulesErr).
+			Str("job_id", jobID).
+			Msg("Failed to compile include/exclude rules, not filtering")
 	}
+	urls = applyURLRules(ctx, jm.dbQueue, jobID, domain, rules, urls)
 
 	// Add URLs to the job queue
 	if len(urls) > 0 {
@@ -752,7 +1113,7 @@ func (jm *JobManager) processSitemap(ctx context.Context, jobID, domain string,
 		}
 
 		// Create page records and get their IDs
-		pageIDs, paths, err := jm.createPageRecords(ctx, domainID, urls)
+		pages, err := createPageRecords(ctx, jm.dbQueue, domainID, urls, job.URLPolicy)
 		if err != nil {
 			span.SetTag("error", "true")
 			span.SetData("error.message", err.Error())
@@ -763,7 +1124,16 @@ func (jm *JobManager) processSitemap(ctx context.Context, jobID, domain string,
 				Msg("Failed to create page records")
 			return
 		}
+		pageIDs, paths := pageIDsAndPaths(pages)
 		
+		// Store sitemap metadata so tasks can be ordered by priority
+		if err := jm.savePageMetadata(ctx, pages, entries); err != nil {
+			log.Error().
+				Err(err).
+				Str("job_id", jobID).
+				Msg("Failed to save sitemap page metadata")
+		}
+
 		// Update sitemap task count in the job
 		err = jm.dbQueue.Execute(ctx, func(tx *sql.Tx) error {
 			_, err := tx.ExecContext(ctx, `
@@ -782,7 +1152,7 @@ func (jm *JobManager) processSitemap(ctx context.Context, jobID, domain string,
 		
 		// Use our wrapper function that checks for duplicates
 		baseURL := fmt.Sprintf("https://%s", domain)
-		if err := jm.EnqueueJobURLs(ctx, jobID, pageIDs, paths, "sitemap", baseURL); err != nil {
+		if err := jm.EnqueueJobURLs(ctx, jobID, pageIDs, paths, "sitemap", baseURL, nil); err != nil {
 			span.SetTag("error", "true")
 			span.SetData("error.message", err.Error())
 			log.Error().
@@ -798,6 +1168,8 @@ func (jm *JobManager) processSitemap(ctx context.Context, jobID, domain string,
 			Str("domain", domain).
 			Int("url_count", len(urls)).
 			Msg("Added sitemap URLs to job queue")
+
+		jm.enqueueSitemapExtensions(ctx, job, rules, domainID, urls, entries, baseURL)
 	} else {
 		log.Info().
 			Str("job_id", jobID).
@@ -818,8 +1190,8 @@ func (jm *JobManager) processSitemap(ctx context.Context, jobID, domain string,
 	}
 
 	// Start the job if it's in pending state
-	job, err := jm.GetJob(ctx, jobID)
-	if err == nil && job.Status == JobStatusPending {
+	current, err := jm.GetJob(ctx, jobID)
+	if err == nil && current.Status == JobStatusPending {
 		if err := jm.StartJob(ctx, jobID); err != nil {
 			log.Error().
 				Err(err).
@@ -0,0 +1,209 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Harvey-AU/blue-banded-bee/internal/crawler"
+)
+
+// fakeQueue records the tasks enqueued without a database. Transactions run
+// against query when it's set, and are skipped otherwise.
+type fakeQueue struct {
+	enqueued []enqueuedBatch
+	query    fakeQuery
+}
+
+// fakeQuery answers a query with the rows it returns
+type fakeQuery func(query string, args []driver.Value) ([][]driver.Value, error)
+
+type enqueuedBatch struct {
+	paths        []string
+	sourceType   string
+	parentTaskID string
+	depth        int
+}
+
+func (q *fakeQueue) Execute(ctx context.Context, fn func(*sql.Tx) error) error {
+	if q.query == nil {
+		return nil
+	}
+	db := sql.OpenDB(fakeConnector{q.query})
+	defer db.Close()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (q *fakeQueue) EnqueueURLs(ctx context.Context, jobID string, pageIDs []int, paths []string, sourceType string, sourceURL string, parentTaskID string, depth int) error {
+	q.enqueued = append(q.enqueued, enqueuedBatch{paths, sourceType, parentTaskID, depth})
+	return nil
+}
+
+func (q *fakeQueue) CleanupStuckJobs(ctx context.Context) error { return nil }
+
+// fakeConnector is a database/sql driver whose queries are answered by a fakeQuery
+type fakeConnector struct{ query fakeQuery }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn(c), nil }
+func (c fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ query fakeQuery }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.query, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return c, nil }
+func (c fakeConn) Commit() error                             { return nil }
+func (c fakeConn) Rollback() error                           { return nil }
+
+type fakeStmt struct {
+	fn    fakeQuery
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	_, err := s.fn(s.query, args)
+	return driver.RowsAffected(0), err
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, err := s.fn(s.query, args)
+	return &fakeRows{rows: rows}, err
+}
+
+type fakeRows struct{ rows [][]driver.Value }
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return []string{""}
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestEnqueueJobURLsDepth(t *testing.T) {
+	queue := &fakeQueue{}
+	jm := NewJobManager(nil, queue, nil, nil)
+	ctx := context.Background()
+
+	if err := jm.EnqueueJobURLs(ctx, "job-1", []int{1}, []string{"/"}, "sitemap", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	seed := &Task{ID: "task-1", JobID: "job-1", Depth: 0, MaxDepth: 2}
+	if err := jm.EnqueueJobURLs(ctx, "job-1", []int{2}, []string{"/a"}, "link", "https://example.com/", seed); err != nil {
+		t.Fatal(err)
+	}
+	child := &Task{ID: "task-2", JobID: "job-1", Depth: 1, MaxDepth: 2}
+	if err := jm.EnqueueJobURLs(ctx, "job-1", []int{3}, []string{"/a/b"}, "link", "https://example.com/a", child); err != nil {
+		t.Fatal(err)
+	}
+	grandchild := &Task{ID: "task-3", JobID: "job-1", Depth: 2, MaxDepth: 2}
+	if err := jm.EnqueueJobURLs(ctx, "job-1", []int{4}, []string{"/a/b/c"}, "link", "https://example.com/a/b", grandchild); err != nil {
+		t.Fatal(err)
+	}
+	// Only links are limited by depth
+	if err := jm.EnqueueJobURLs(ctx, "job-1", []int{5}, []string{"/style.css"}, "asset", "https://example.com/a/b", grandchild); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []enqueuedBatch{
+		{[]string{"/"}, "sitemap", "", 0},
+		{[]string{"/a"}, "link", "task-1", 1},
+		{[]string{"/a/b"}, "link", "task-2", 2},
+		{[]string{"/style.css"}, "asset", "task-3", 3},
+	}
+	if len(queue.enqueued) != len(want) {
+		t.Fatalf("enqueued %d batches, want %d: %+v", len(queue.enqueued), len(want), queue.enqueued)
+	}
+	for i, got := range queue.enqueued {
+		w := want[i]
+		if got.paths[0] != w.paths[0] || got.sourceType != w.sourceType || got.parentTaskID != w.parentTaskID || got.depth != w.depth {
+			t.Errorf("batch %d = %+v, want %+v", i, got, w)
+		}
+	}
+
+	// A page dropped for depth can still be enqueued when it's found closer to a seed
+	if err := jm.EnqueueJobURLs(ctx, "job-1", []int{4}, []string{"/a/b/c"}, "link", "https://example.com/", seed); err != nil {
+		t.Fatal(err)
+	}
+	if last := queue.enqueued[len(queue.enqueued)-1]; last.paths[0] != "/a/b/c" || last.depth != 1 {
+		t.Errorf("re-found page enqueued as %+v, want depth 1", last)
+	}
+}
+
+func TestJobSitemaps(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("Sitemap: https://example.com/sitemap.xml\n"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	domain := strings.TrimPrefix(server.URL, "http://")
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	unreachable := strings.TrimPrefix(closed.URL, "http://")
+
+	feeds := []string{"https://example.com/feed/"}
+	tests := []struct {
+		name          string
+		domain        string
+		sitemapURLs   []string
+		feedURLs      []string
+		want          []string
+		wantDiscovery bool
+	}{
+		{"discovered", domain, nil, nil, []string{"https://example.com/sitemap.xml"}, true},
+		// Feeds are read alongside the discovered sitemaps, and the site is still recorded
+		{"discovered with feeds", domain, nil, feeds, []string{"https://example.com/sitemap.xml", "https://example.com/feed/"}, true},
+		{"named sitemaps", domain, []string{"https://example.com/posts.xml"}, feeds, []string{"https://example.com/posts.xml", "https://example.com/feed/"}, false},
+		{"feeds without discovery", unreachable, nil, feeds, feeds, false},
+	}
+	for _, tt := range tests {
+		sitemaps, discovery, err := jobSitemaps(context.Background(), crawler.New(nil), tt.domain, tt.sitemapURLs, tt.feedURLs)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !slices.Equal(sitemaps, tt.want) {
+			t.Errorf("%s: sitemaps = %v, want %v", tt.name, sitemaps, tt.want)
+		}
+		if (discovery != nil) != tt.wantDiscovery {
+			t.Errorf("%s: discovery = %+v, want discovery %v", tt.name, discovery, tt.wantDiscovery)
+		}
+		if discovery != nil && discovery.BaseURL != server.URL {
+			t.Errorf("%s: BaseURL = %q, want %q", tt.name, discovery.BaseURL, server.URL)
+		}
+	}
+
+	if _, _, err := jobSitemaps(context.Background(), crawler.New(nil), unreachable, nil, nil); err == nil {
+		t.Error("discovery failing without feeds should return an error")
+	}
+}
@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Harvey-AU/blue-banded-bee/internal/crawler"
+	"github.com/getsentry/sentry-go"
+	"github.com/rs/zerolog/log"
+)
+
+// pageRecord is the page created for a URL
+type pageRecord struct {
+	id   int
+	path string // Path tasks request, or the full URL for pages on other hosts
+	url  string // Canonical URL the page was created for
+}
+
+// createPageRecords canonicalises a list of URLs with the job's policy and
+// creates a page record for each URL that leaves. Both the job manager and the
+// worker pool create pages through it.
+func createPageRecords(ctx context.Context, q txExecutor, domainID int, urls []string, policy crawler.URLPolicy) ([]pageRecord, error) {
+	span := sentry.StartSpan(ctx, "jobs.create_page_records")
+	defer span.Finish()
+
+	span.SetTag("domain_id", fmt.Sprintf("%d", domainID))
+	span.SetTag("url_count", fmt.Sprintf("%d", len(urls)))
+
+	// URLs that are the same page once canonicalised share a record
+	urls = policy.CanonicalizeURLs(urls)
+	if len(urls) == 0 {
+		return []pageRecord{}, nil
+	}
+
+	// Get domain name from the database
+	var domainName string
+	err := q.Execute(ctx, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, `
+			SELECT name FROM domains WHERE id = $1
+		`, domainID).Scan(&domainName)
+	})
+	if err != nil {
+		span.SetTag("error", "true")
+		span.SetData("error.message", err.Error())
+		return nil, fmt.Errorf("failed to get domain name: %w", err)
+	}
+
+	// Pages on other hosts keep their full URL
+	pages := make([]pageRecord, 0, len(urls))
+	for _, url := range urls {
+		pages = append(pages, pageRecord{path: pagePath(domainName, url), url: url})
+	}
+
+	// Insert pages into database in a transaction
+	err = q.Execute(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `
+			INSERT INTO pages (domain_id, path)
+			VALUES ($1, $2)
+			ON CONFLICT (domain_id, path) DO UPDATE SET path = EXCLUDED.path
+			RETURNING id
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare page insert statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for i := range pages {
+			if err := stmt.QueryRowContext(ctx, domainID, pages[i].path).Scan(&pages[i].id); err != nil {
+				return fmt.Errorf("failed to insert page record: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		span.SetTag("error", "true")
+		span.SetData("error.message", err.Error())
+		return nil, fmt.Errorf("failed to create page records: %w", err)
+	}
+
+	log.Debug().
+		Int("domain_id", domainID).
+		Int("page_count", len(pages)).
+		Msg("Created page records")
+
+	return pages, nil
+}
+
+// pageIDsAndPaths returns the IDs and paths tasks are enqueued with
+func pageIDsAndPaths(pages []pageRecord) ([]int, []string) {
+	pageIDs := make([]int, len(pages))
+	paths := make([]string, len(pages))
+	for i, page := range pages {
+		pageIDs[i] = page.id
+		paths[i] = page.path
+	}
+	return pageIDs, paths
+}
@@ -0,0 +1,62 @@
+package jobs
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/Harvey-AU/blue-banded-bee/internal/crawler"
+)
+
+func TestCreatePageRecords(t *testing.T) {
+	ids := map[string]int64{"/a?a=1&b=2": 7, "/b": 9}
+	metadata := make(map[int64]string)
+	queue := &fakeQueue{query: func(query string, args []driver.Value) ([][]driver.Value, error) {
+		switch {
+		case strings.Contains(query, "SELECT name FROM domains"):
+			return [][]driver.Value{{"example.com"}}, nil
+		case strings.Contains(query, "INSERT INTO pages"):
+			return [][]driver.Value{{ids[args[1].(string)]}}, nil
+		case strings.Contains(query, "UPDATE pages"):
+			metadata[args[0].(int64)] = args[2].(string)
+		}
+		return nil, nil
+	}}
+
+	urls := []string{
+		"https://Example.com/a?utm_source=x&b=2&a=1",
+		"https://example.com/b#top",
+		"https://example.com/a?a=1&b=2",
+	}
+	pages, err := createPageRecords(context.Background(), queue, 1, urls, crawler.URLPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// URLs that canonicalise to the same page share its record
+	want := []pageRecord{
+		{id: 7, path: "/a?a=1&b=2", url: "https://example.com/a?a=1&b=2"},
+		{id: 9, path: "/b", url: "https://example.com/b"},
+	}
+	if len(pages) != len(want) {
+		t.Fatalf("pages = %+v, want %+v", pages, want)
+	}
+	for i := range want {
+		if pages[i] != want[i] {
+			t.Errorf("pages[%d] = %+v, want %+v", i, pages[i], want[i])
+		}
+	}
+
+	// Metadata is matched to each page by the URL its record was created for
+	jm := NewJobManager(nil, queue, nil, nil)
+	entries := map[string]crawler.SitemapEntry{
+		"https://example.com/b": {Loc: "https://example.com/b", ChangeFreq: "daily"},
+	}
+	if err := jm.savePageMetadata(context.Background(), pages, entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(metadata) != 1 || metadata[9] != "daily" {
+		t.Errorf("metadata = %v, want page 9 daily", metadata)
+	}
+}
@@ -0,0 +1,153 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Harvey-AU/blue-banded-bee/internal/crawler"
+	"github.com/getsentry/sentry-go"
+	"github.com/rs/zerolog/log"
+)
+
+// Preview defaults
+const (
+	DefaultPreviewSampleSize = 20
+	defaultPreviewPageTime   = time.Second // Assumed per page when the domain has no warmed history
+)
+
+// JobPreview describes what a job with the given options would warm
+type JobPreview struct {
+	Domain            string                  `json:"domain"`
+	BaseURL           string                  `json:"base_url,omitempty"`
+	Sitemaps          []crawler.SitemapResult `json:"sitemaps"`
+	URLsFound         int                     `json:"urls_found"`                // Distinct page URLs listed in the sitemaps, once canonicalised
+	NotModified       int                     `json:"not_modified"`              // URLs dropped by the modified-since cutoff
+	URLsAfterFilter   int                     `json:"urls_after_filter"`         // URLs left after the cutoff and include/exclude rules
+	RuleExclusions    map[string]int          `json:"rule_exclusions,omitempty"` // URLs each include/exclude rule dropped
+	SampleURLs        []string                `json:"sample_urls"`               // Evenly spaced URLs from the filtered set
+	Concurrency       int                     `json:"concurrency"`
+	AvgPageTimeMs     int64                   `json:"avg_page_time_ms"` // From the domain's completed tasks, or a default
+	EstimatedDuration float64                 `json:"estimated_duration_seconds"`
+}
+
+// PreviewJob runs sitemap discovery, traversal and filtering for the options
+// without creating a job or any tasks
+func (jm *JobManager) PreviewJob(ctx context.Context, options *JobOptions, sampleSize int) (*JobPreview, error) {
+	span := sentry.StartSpan(ctx, "jobs.preview_job")
+	defer span.Finish()
+
+	if options == nil {
+		return nil, fmt.Errorf("job options are required")
+	}
+	normalizedDomain := strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(options.Domain, "http://"), "https://"), "www.")
+	normalizedDomain = strings.TrimSuffix(normalizedDomain, "/")
+	span.SetTag("domain", normalizedDomain)
+
+	concurrency := options.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	preview := &JobPreview{Domain: normalizedDomain, Concurrency: concurrency}
+
+	rules, err := crawler.CompileURLRules(options.IncludePaths, options.ExcludePaths, options.RulePrecedence)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include/exclude rules: %w", err)
+	}
+
+	crawlerConfig := crawler.DefaultConfig()
+	crawlerConfig.Auth = options.Auth
+	previewCrawler := crawler.New(crawlerConfig)
+	defer previewCrawler.CloseIdleConnections()
+
+	sitemaps, discovery, err := jobSitemaps(ctx, previewCrawler, normalizedDomain, options.SitemapURLs, options.FeedURLs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover sitemaps: %w", err)
+	}
+	if discovery != nil {
+		preview.BaseURL = discovery.BaseURL
+	}
+
+	limits := previewCrawler.DefaultSitemapLimits()
+	if options.MaxSitemapURLs > 0 {
+		limits.MaxURLs = options.MaxSitemapURLs
+	}
+	entries, results := previewCrawler.TraverseSitemaps(ctx, sitemaps, limits)
+	entries = options.URLPolicy.CanonicalizeEntries(entries)
+	preview.Sitemaps = results
+	preview.URLsFound = len(entries)
+
+	var urls []string
+	for _, entry := range entries {
+		if options.ModifiedSince != nil && !entry.LastMod.IsZero() && !entry.LastMod.After(*options.ModifiedSince) {
+			preview.NotModified++
+			continue
+		}
+		urls = append(urls, entry.Loc)
+	}
+	urls, excluded := rules.Filter(urls)
+	for rule, excludedURLs := range excluded {
+		if preview.RuleExclusions == nil {
+			preview.RuleExclusions = make(map[string]int, len(excluded))
+		}
+		preview.RuleExclusions[rule] = len(excludedURLs)
+	}
+	preview.URLsAfterFilter = len(urls)
+	if sampleSize <= 0 {
+		sampleSize = DefaultPreviewSampleSize
+	}
+	preview.SampleURLs = sampleURLs(urls, sampleSize)
+
+	pageTime, err := jm.averagePageTime(ctx, normalizedDomain)
+	if err != nil {
+		log.Warn().Err(err).Str("domain", normalizedDomain).Msg("Failed to get average page time, using default")
+	}
+	if pageTime <= 0 {
+		pageTime = defaultPreviewPageTime
+	}
+	preview.AvgPageTimeMs = pageTime.Milliseconds()
+	batches := math.Ceil(float64(len(urls)) / float64(concurrency))
+	preview.EstimatedDuration = batches * pageTime.Seconds()
+
+	log.Info().
+		Str("domain", normalizedDomain).
+		Int("sitemap_count", len(results)).
+		Int("urls_found", preview.URLsFound).
+		Int("urls_after_filter", preview.URLsAfterFilter).
+		Float64("estimated_duration_seconds", preview.EstimatedDuration).
+		Msg("Previewed job")
+
+	return preview, nil
+}
+
+// averagePageTime returns the mean response time of the domain's completed tasks
+func (jm *JobManager) averagePageTime(ctx context.Context, domain string) (time.Duration, error) {
+	var avgMs sql.NullFloat64
+	err := jm.db.QueryRowContext(ctx, `
+		SELECT AVG(t.response_time)
+		FROM tasks t
+		JOIN jobs j ON t.job_id = j.id
+		JOIN domains d ON j.domain_id = d.id
+		WHERE d.name = $1 AND t.status = $2 AND t.response_time IS NOT NULL
+	`, domain, string(TaskStatusCompleted)).Scan(&avgMs)
+	if err != nil || !avgMs.Valid {
+		return 0, err
+	}
+	return time.Duration(avgMs.Float64 * float64(time.Millisecond)), nil
+}
+
+// sampleURLs picks up to n evenly spaced URLs so the sample covers the whole set
+func sampleURLs(urls []string, n int) []string {
+	if len(urls) <= n {
+		return urls
+	}
+	sample := make([]string, 0, n)
+	step := float64(len(urls)) / float64(n)
+	for i := 0; i < n; i++ {
+		sample = append(sample, urls[int(float64(i)*step)])
+	}
+	return sample
+}
@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSampleURLs(t *testing.T) {
+	var urls []string
+	for i := 0; i < 100; i++ {
+		urls = append(urls, fmt.Sprintf("https://example.com/%d", i))
+	}
+
+	sample := sampleURLs(urls, 4)
+	want := []string{"https://example.com/0", "https://example.com/25", "https://example.com/50", "https://example.com/75"}
+	if len(sample) != len(want) {
+		t.Fatalf("sample = %v, want %v", sample, want)
+	}
+	for i := range want {
+		if sample[i] != want[i] {
+			t.Errorf("sample[%d] = %q, want %q", i, sample[i], want[i])
+		}
+	}
+
+	if got := sampleURLs(urls[:3], 10); len(got) != 3 {
+		t.Errorf("sample of a small set = %v, want all 3 URLs", got)
+	}
+}
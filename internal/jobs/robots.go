@@ -0,0 +1,143 @@
+package jobs
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Harvey-AU/blue-banded-bee/internal/crawler"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
+)
+
+// robotsSkipReason is recorded on tasks whose path robots.txt disallows
+const robotsSkipReason = "disallowed by robots.txt"
+
+// maxCrawlDelay caps the Crawl-delay applied to a job so a large value can't stall it
+const maxCrawlDelay = 30 * time.Second
+
+// robotsFetchTimeout bounds a robots.txt fetch, which no single task's context does
+const robotsFetchTimeout = 30 * time.Second
+
+// robotsRetryInterval is how long a job allows all paths after robots.txt
+// couldn't be fetched before fetching it again
+const robotsRetryInterval = time.Minute
+
+// jobRobots holds a job's robots.txt rules, fetched once for the life of the
+// job. A failed fetch is retried rather than kept.
+type jobRobots struct {
+	mu      sync.Mutex
+	fetched bool
+	retryAt time.Time // When a failed fetch may be retried
+	rules   *crawler.RobotsRules
+	limiter *rate.Limiter // Spaces requests by the Crawl-delay, nil when there is none
+}
+
+// robotsFor returns the robots.txt rules for a task's job, fetching them on first use
+func (wp *WorkerPool) robotsFor(ctx context.Context, task *Task) *jobRobots {
+	wp.robotsMutex.Lock()
+	robots, ok := wp.robots[task.JobID]
+	if !ok {
+		robots = &jobRobots{}
+		wp.robots[task.JobID] = robots
+	}
+	wp.robotsMutex.Unlock()
+
+	robots.mu.Lock()
+	defer robots.mu.Unlock()
+	if robots.fetched || time.Now().Before(robots.retryAt) {
+		return robots
+	}
+
+	// The rules serve the whole job, so cancelling the task that happens to
+	// fetch them mustn't cut the fetch short
+	fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), robotsFetchTimeout)
+	defer cancel()
+
+	rules, err := wp.crawler.FetchRobots(fetchCtx, task.DomainName)
+	if err != nil {
+		// An unreachable site doesn't block the job, but robots.txt is tried again
+		robots.retryAt = time.Now().Add(robotsRetryInterval)
+		log.Warn().Err(err).Str("job_id", task.JobID).Str("domain", task.DomainName).Dur("retry_in", robotsRetryInterval).Msg("Failed to fetch robots.txt, allowing all paths until it is fetched")
+		return robots
+	}
+	robots.fetched = true
+	robots.rules = rules
+
+	delay := rules.CrawlDelay(wp.crawler.Config().UserAgent)
+	if delay <= 0 {
+		return robots
+	}
+	if delay > maxCrawlDelay {
+		log.Warn().Dur("crawl_delay", delay).Dur("max_crawl_delay", maxCrawlDelay).Str("job_id", task.JobID).Msg("Capping robots.txt Crawl-delay")
+		delay = maxCrawlDelay
+	}
+	robots.limiter = rate.NewLimiter(rate.Every(delay), 1)
+
+	if _, err := wp.db.ExecContext(fetchCtx, `UPDATE jobs SET crawl_delay_ms = $1 WHERE id = $2`, delay.Milliseconds(), task.JobID); err != nil {
+		log.Error().Err(err).Str("job_id", task.JobID).Msg("Failed to record crawl delay")
+	}
+	log.Info().Str("job_id", task.JobID).Dur("crawl_delay", delay).Msg("Applying robots.txt Crawl-delay")
+
+	return robots
+}
+
+// allowed reports whether robots.txt lets the crawler fetch the task's path.
+// Only paths on the job's domain are covered by its robots.txt.
+func (r *jobRobots) allowed(userAgent string, task *Task) bool {
+	path, ok := robotsPath(task.DomainName, task.Path)
+	if !ok {
+		return true
+	}
+	r.mu.Lock()
+	rules := r.rules
+	r.mu.Unlock()
+	return rules.Allowed(userAgent, path)
+}
+
+// wait blocks until the Crawl-delay since the job's previous request has passed
+func (r *jobRobots) wait(ctx context.Context) error {
+	r.mu.Lock()
+	limiter := r.limiter
+	r.mu.Unlock()
+	if limiter == nil {
+		return nil
+	}
+	return limiter.Wait(ctx)
+}
+
+// crawlDelayWait returns the wait made before each of a task's requests, so the
+// Crawl-delay spaces every variant, edge and retry rather than only each task.
+// Jobs that don't respect robots.txt don't wait.
+func (wp *WorkerPool) crawlDelayWait(task *Task) func(ctx context.Context) error {
+	if !task.RespectRobots {
+		return nil
+	}
+	return func(ctx context.Context) error {
+		return wp.robotsFor(ctx, task).wait(ctx)
+	}
+}
+
+// robotsPath returns the path and query of a task path on the job's domain
+func robotsPath(domain, path string) (string, bool) {
+	if strings.HasPrefix(path, "/") {
+		return path, true
+	}
+	u, err := url.Parse(path)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	if strings.TrimPrefix(strings.ToLower(u.Host), "www.") != strings.TrimPrefix(strings.ToLower(domain), "www.") {
+		return "", false
+	}
+	return u.RequestURI(), true
+}
+
+// forgetRobots drops the cached robots.txt rules of a job
+func (wp *WorkerPool) forgetRobots(jobID string) {
+	wp.robotsMutex.Lock()
+	delete(wp.robots, jobID)
+	wp.robotsMutex.Unlock()
+}
@@ -0,0 +1,95 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Harvey-AU/blue-banded-bee/internal/crawler"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// txExecutor runs a function in a database transaction, as both the job
+// manager's and the worker pool's queues do
+type txExecutor interface {
+	Execute(ctx context.Context, fn func(*sql.Tx) error) error
+}
+
+// jobRules compiles a job's include and exclude paths
+func jobRules(job *Job) (*crawler.URLRules, error) {
+	return crawler.CompileURLRules(job.IncludePaths, job.ExcludePaths, job.RulePrecedence)
+}
+
+// applyURLRules returns the URLs a job's rules keep, and records the paths of
+// the others against the rule that excluded them so the job can report it
+func applyURLRules(ctx context.Context, q txExecutor, jobID, domain string, rules *crawler.URLRules, urls []string) []string {
+	kept, excluded := rules.Filter(urls)
+	if len(excluded) == 0 {
+		return kept
+	}
+
+	err := q.Execute(ctx, func(tx *sql.Tx) error {
+		for rule, excludedURLs := range excluded {
+			paths := make([]string, len(excludedURLs))
+			for i, u := range excludedURLs {
+				paths[i] = pagePath(domain, u)
+			}
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO job_excluded_urls (job_id, rule, path)
+				SELECT $1, $2, unnest($3::text[])
+				ON CONFLICT DO NOTHING
+			`, jobID, rule, pq.Array(paths)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("job_id", jobID).
+			Msg("Failed to record URLs excluded by rules")
+	}
+
+	log.Debug().
+		Str("job_id", jobID).
+		Int("kept_count", len(kept)).
+		Int("excluded_count", len(urls)-len(kept)).
+		Msg("Applied include/exclude rules")
+
+	return kept
+}
+
+// rootExcluded reports whether a job's rules exclude its root URL, in which
+// case the exclusion is recorded and the job given an error message, as it has
+// nothing to start from
+func (jm *JobManager) rootExcluded(ctx context.Context, job *Job) bool {
+	rules, err := jobRules(job)
+	if err != nil {
+		return false
+	}
+	rootURL := fmt.Sprintf("https://%s/", job.Domain)
+	if kept := applyURLRules(ctx, jm.dbQueue, job.ID, job.Domain, rules, []string{rootURL}); len(kept) > 0 {
+		return false
+	}
+
+	log.Warn().
+		Str("job_id", job.ID).
+		Str("domain", job.Domain).
+		Msg("Root URL excluded by include/exclude rules")
+
+	job.ErrorMessage = "Root URL excluded by include/exclude rules"
+	err = jm.dbQueue.Execute(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE jobs
+			SET error_message = $1
+			WHERE id = $2
+		`, job.ErrorMessage, job.ID)
+		return err
+	})
+	if err != nil {
+		log.Error().Err(err).Str("job_id", job.ID).Msg("Failed to update job with error message")
+	}
+	return true
+}
@@ -2,6 +2,8 @@ package jobs
 
 import (
 	"time"
+
+	"github.com/Harvey-AU/blue-banded-bee/internal/crawler"
 )
 
 // JobStatus represents the current status of a job
@@ -36,25 +38,55 @@ const (
 
 // Job represents a crawling job for a domain
 type Job struct {
-	ID              string    `json:"id"`
-	Domain          string    `json:"domain"`
-	Status          JobStatus `json:"status"`
-	Progress        float64   `json:"progress"`
-	TotalTasks      int       `json:"total_tasks"`
-	CompletedTasks  int       `json:"completed_tasks"`
-	FailedTasks     int       `json:"failed_tasks"`
-	FoundTasks      int       `json:"found_tasks"`
-	SitemapTasks    int       `json:"sitemap_tasks"`
-	CreatedAt       time.Time `json:"created_at"`
-	StartedAt       time.Time `json:"started_at,omitempty"`
-	CompletedAt     time.Time `json:"completed_at,omitempty"`
-	Concurrency     int       `json:"concurrency"`
-	FindLinks       bool      `json:"find_links"`
-	MaxPages        int       `json:"max_pages"`
-	IncludePaths    []string  `json:"include_paths,omitempty"`
-	ExcludePaths    []string  `json:"exclude_paths,omitempty"`
-	RequiredWorkers int       `json:"required_workers"`
-	ErrorMessage    string    `json:"error_message,omitempty"`
+	ID               string                 `json:"id"`
+	Domain           string                 `json:"domain"`
+	Status           JobStatus              `json:"status"`
+	Progress         float64                `json:"progress"`
+	TotalTasks       int                    `json:"total_tasks"`
+	CompletedTasks   int                    `json:"completed_tasks"`
+	FailedTasks      int                    `json:"failed_tasks"`
+	SkippedTasks     int                    `json:"skipped_tasks"`
+	FoundTasks       int                    `json:"found_tasks"`
+	SitemapTasks     int                    `json:"sitemap_tasks"`
+	CreatedAt        time.Time              `json:"created_at"`
+	StartedAt        time.Time              `json:"started_at,omitempty"`
+	CompletedAt      time.Time              `json:"completed_at,omitempty"`
+	Concurrency      int                    `json:"concurrency"`
+	FindLinks        bool                   `json:"find_links"`
+	MaxPages         int                    `json:"max_pages"`
+	IncludePaths     []string               `json:"include_paths,omitempty"`
+	ExcludePaths     []string               `json:"exclude_paths,omitempty"`
+	RequiredWorkers  int                    `json:"required_workers"`
+	ErrorMessage     string                 `json:"error_message,omitempty"`
+	VerifyCache      bool                   `json:"verify_cache"`
+	VerifyAttempts   int                    `json:"verify_attempts,omitempty"`
+	VerifyDelayMs    int                    `json:"verify_delay_ms,omitempty"`
+	WarmedTasks      int                    `json:"warmed_tasks"`
+	MaxBodySize      int64                  `json:"max_body_size,omitempty"`
+	TotalBytes       int64                  `json:"total_bytes"`
+	Variants         []RequestVariant       `json:"variants,omitempty"`
+	Edges            []string               `json:"edges,omitempty"`
+	DNSServers       []string               `json:"dns_servers,omitempty"`
+	Auth             *crawler.RequestAuth   `json:"auth,omitempty"` // Secret values are redacted when encoded
+	ModifiedSince    *time.Time             `json:"modified_since,omitempty"`
+	MaxSitemapURLs   int                    `json:"max_sitemap_urls,omitempty"`
+	FeedURLs         []string               `json:"feed_urls,omitempty"`
+	IncludeHreflang  bool                   `json:"include_hreflang"`
+	IncludeImages    bool                   `json:"include_images"`
+	IncludeVideos    bool                   `json:"include_videos"`
+	RespectRobots    bool                   `json:"respect_robots"`
+	CrawlDelayMs     int                    `json:"crawl_delay_ms,omitempty"` // Spacing between requests taken from robots.txt
+	SitemapURLs      []string               `json:"sitemap_urls,omitempty"`
+	BaseURL          string                 `json:"base_url,omitempty"`  // Scheme and host discovery found the site served from
+	Redirects        []crawler.SiteRedirect `json:"redirects,omitempty"` // Redirects followed to reach the base URL and sitemaps
+	OnlyAddedURLs    bool                   `json:"only_added_urls"`
+	FindAssets       bool                   `json:"find_assets"`
+	AssetHosts       []string               `json:"asset_hosts,omitempty"`
+	URLPolicy        crawler.URLPolicy      `json:"url_policy"`
+	RespectNofollow  bool                   `json:"respect_nofollow"`
+	EnqueueCanonical bool                   `json:"enqueue_canonical"`
+	MaxDepth         int                    `json:"max_depth"`
+	RulePrecedence   string                 `json:"rule_precedence,omitempty"`
 }
 
 // Task represents a single URL to be crawled within a job
@@ -70,31 +102,109 @@ type Task struct {
 	CompletedAt time.Time  `json:"completed_at,omitempty"`
 	RetryCount  int        `json:"retry_count"`
 	Error       string     `json:"error,omitempty"`
+	SkipReason  string     `json:"skip_reason,omitempty"`
 
 	// Source information
-	SourceType string `json:"source_type"`          // "sitemap", "link", "manual"
-	SourceURL  string `json:"source_url,omitempty"` // URL where this was discovered (for links)
+	SourceType   string `json:"source_type"`              // "sitemap", "hreflang", "sitemap_image", "sitemap_video", "link", "asset", "canonical", "manual"
+	SourceURL    string `json:"source_url,omitempty"`     // URL where this was discovered (for links)
+	Depth        int    `json:"depth"`                    // Links followed from a seed URL to reach this one
+	ParentTaskID string `json:"parent_task_id,omitempty"` // Task this URL was discovered on
 
 	// Result data
 	StatusCode   int    `json:"status_code,omitempty"`
 	ResponseTime int64  `json:"response_time,omitempty"`
 	CacheStatus  string `json:"cache_status,omitempty"`
 	ContentType  string `json:"content_type,omitempty"`
+	CanonicalURL string `json:"canonical_url,omitempty"` // Canonical URL the page declares
+
+	// Content or cache warning for a completed task
+	Warning     string `json:"warning,omitempty"`
+	WarningType string `json:"warning_type,omitempty"`
+
+	// Failed requests for the other variants and edges of a completed task
+	VariantFailures string `json:"variant_failures,omitempty"`
+
+	// Request attempts made while warming
+	Attempts      int      `json:"attempts,omitempty"`
+	AttemptErrors []string `json:"attempt_errors,omitempty"`
+
+	// Body accounting
+	BytesTransferred int64 `json:"bytes_transferred"`
+	ContentLength    int64 `json:"content_length,omitempty"`
+	BodyTruncated    bool  `json:"body_truncated,omitempty"`
+
+	// Timing breakdown in milliseconds
+	DNSLookupTime    int64 `json:"dns_lookup_time,omitempty"`
+	TCPConnectTime   int64 `json:"tcp_connect_time,omitempty"`
+	TLSHandshakeTime int64 `json:"tls_handshake_time,omitempty"`
+	TTFB             int64 `json:"ttfb,omitempty"`
+	DownloadTime     int64 `json:"download_time,omitempty"`
+	ConnReused       bool  `json:"conn_reused,omitempty"`
+
+	// Cache detection results
+	CacheStatusRaw string `json:"cache_status_raw,omitempty"`
+	CDN            string `json:"cdn,omitempty"`
+
+	// Cache verification results
+	FirstCacheStatus string `json:"first_cache_status,omitempty"`
+	CacheAttempts    int    `json:"cache_attempts,omitempty"`
+	CacheVerified    bool   `json:"cache_verified"`
 
 	// Job configuration that affects processing
-	FindLinks bool `json:"-"` // Not stored in DB, just used during processing
+	FindLinks        bool                 `json:"-"` // Not stored in DB, just used during processing
+	VerifyCache      bool                 `json:"-"`
+	VerifyAttempts   int                  `json:"-"`
+	VerifyDelay      time.Duration        `json:"-"`
+	MaxBodySize      int64                `json:"-"`
+	Variants         []RequestVariant     `json:"-"`
+	Edges            []string             `json:"-"`
+	Auth             *crawler.RequestAuth `json:"-"`
+	RespectRobots    bool                 `json:"-"`
+	BaseURL          string               `json:"-"`
+	FindAssets       bool                 `json:"-"`
+	AssetHosts       []string             `json:"-"`
+	URLPolicy        crawler.URLPolicy    `json:"-"`
+	RespectNofollow  bool                 `json:"-"`
+	EnqueueCanonical bool                 `json:"-"`
+	MaxDepth         int                  `json:"-"`
+	Rules            *crawler.URLRules    `json:"-"`
 }
 
 // JobOptions defines configuration options for a crawl job
 type JobOptions struct {
-	Domain          string   `json:"domain"`
-	UseSitemap      bool     `json:"use_sitemap"`
-	Concurrency     int      `json:"concurrency"`
-	FindLinks       bool     `json:"find_links"`
-	MaxPages        int      `json:"max_pages"`
-	IncludePaths    []string `json:"include_paths,omitempty"`
-	ExcludePaths    []string `json:"exclude_paths,omitempty"`
-	RequiredWorkers int      `json:"required_workers"`
+	Domain               string               `json:"domain"`
+	UseSitemap           bool                 `json:"use_sitemap"`
+	Concurrency          int                  `json:"concurrency"`
+	FindLinks            bool                 `json:"find_links"`
+	MaxPages             int                  `json:"max_pages"`
+	IncludePaths         []string             `json:"include_paths,omitempty"` // Path globs, re: regexes, query: params or substrings a URL must match
+	ExcludePaths         []string             `json:"exclude_paths,omitempty"` // Patterns, as for IncludePaths, whose URLs are never warmed
+	RequiredWorkers      int                  `json:"required_workers"`
+	VerifyCache          bool                 `json:"verify_cache"`             // Re-request pages until the edge reports a HIT
+	VerifyAttempts       int                  `json:"verify_attempts"`          // Maximum follow-up requests per page (0 uses the crawler default)
+	VerifyDelayMs        int                  `json:"verify_delay_ms"`          // Delay between follow-up requests (0 uses the crawler default)
+	MaxBodySize          int64                `json:"max_body_size"`            // Maximum body bytes read per page (0 uses the crawler default, -1 is unlimited)
+	Variants             []RequestVariant     `json:"variants,omitempty"`       // Request variants each page is warmed with (none sends a single default request)
+	Edges                []string             `json:"edges,omitempty"`          // Edge IPs each page is warmed at, in addition to normal resolution
+	DNSServers           []string             `json:"dns_servers,omitempty"`    // DNS servers whose answers for the domain are added to the edges
+	Auth                 *crawler.RequestAuth `json:"auth,omitempty"`           // Headers, basic auth and cookies for protected sites, encrypted at rest
+	ModifiedSince        *time.Time           `json:"modified_since,omitempty"` // Only warm sitemap pages with a lastmod after this time
+	ModifiedSinceLastJob bool                 `json:"modified_since_last_job"`  // Only warm sitemap pages modified since the last completed job for the domain
+	MaxSitemapURLs       int                  `json:"max_sitemap_urls"`         // Page URLs collected from sitemaps before traversal stops (0 uses the crawler default)
+	FeedURLs             []string             `json:"feed_urls,omitempty"`      // RSS/Atom feeds or text sitemaps read alongside the job's sitemaps
+	IncludeHreflang      bool                 `json:"include_hreflang"`         // Warm hreflang alternates listed in the sitemap
+	IncludeImages        bool                 `json:"include_images"`           // Warm images from the image sitemap extension
+	IncludeVideos        bool                 `json:"include_videos"`           // Warm videos and thumbnails from the video sitemap extension
+	RespectRobots        bool                 `json:"respect_robots"`           // Skip paths robots.txt disallows and space requests by its Crawl-delay
+	SitemapURLs          []string             `json:"sitemap_urls,omitempty"`   // Sitemaps read instead of discovering them
+	OnlyAddedURLs        bool                 `json:"only_added_urls"`          // Only warm sitemap URLs the domain's previous job didn't find
+	FindAssets           bool                 `json:"find_assets"`              // Warm the stylesheets, scripts, images, fonts and media pages reference
+	AssetHosts           []string             `json:"asset_hosts,omitempty"`    // CDN hosts whose assets are warmed along with the domain's own
+	URLPolicy            crawler.URLPolicy    `json:"url_policy"`               // How URLs from every source are canonicalised before they become pages
+	RespectNofollow      bool                 `json:"respect_nofollow"`         // Don't follow rel="nofollow" links, or any link of pages meta robots or X-Robots-Tag mark nofollow
+	EnqueueCanonical     bool                 `json:"enqueue_canonical"`        // Warm the canonical URL a page declares when it differs from the page's own
+	MaxDepth             int                  `json:"max_depth"`                // Links followed from the seed URLs before discovery stops (0 is unlimited)
+	RulePrecedence       string               `json:"rule_precedence"`          // Which wins when a URL matches include and exclude paths, "exclude" (the default) or "include"
 }
 
 // Create a separate CrawlResult struct for batch operations
@@ -0,0 +1,149 @@
+package jobs
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Harvey-AU/blue-banded-bee/internal/db"
+)
+
+// RequestVariant is a named set of request headers. CDNs key their caches on
+// headers such as Accept-Encoding, User-Agent and Accept-Language, so each
+// variant warms a separate cache entry for the same URL.
+type RequestVariant struct {
+	Name    string            `json:"name"`
+	Headers map[string]string `json:"headers"`
+}
+
+// Browser User-Agents used by the device presets
+const (
+	desktopUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 BlueBandedBee"
+	mobileUserAgent  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1 BlueBandedBee"
+)
+
+// defaultVariantName labels results of jobs that warm edges without variants
+const defaultVariantName = "default"
+
+// variantPresets are the variants that can be requested by name
+var variantPresets = map[string]map[string]string{
+	"desktop":  {"User-Agent": desktopUserAgent},
+	"mobile":   {"User-Agent": mobileUserAgent},
+	"gzip":     {"Accept-Encoding": "gzip"},
+	"br":       {"Accept-Encoding": "br"},
+	"identity": {"Accept-Encoding": "identity"},
+}
+
+// VariantPreset returns the named preset variant. Names of the form "lang-xx"
+// produce an Accept-Language variant for that language.
+func VariantPreset(name string) (RequestVariant, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	if headers, ok := variantPresets[name]; ok {
+		return RequestVariant{Name: name, Headers: copyHeaders(headers)}, true
+	}
+
+	if lang, ok := strings.CutPrefix(name, "lang-"); ok && lang != "" {
+		return RequestVariant{Name: name, Headers: map[string]string{"Accept-Language": lang}}, true
+	}
+
+	return RequestVariant{}, false
+}
+
+// ParseVariants resolves a comma-separated list of preset names
+func ParseVariants(names string) ([]RequestVariant, error) {
+	var variants []RequestVariant
+	for _, name := range strings.Split(names, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		variant, ok := VariantPreset(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown variant: %s", name)
+		}
+		variants = append(variants, variant)
+	}
+	return variants, validateVariants(variants)
+}
+
+// validateVariants checks that variant names are present and unique
+func validateVariants(variants []RequestVariant) error {
+	seen := make(map[string]bool, len(variants))
+	for _, v := range variants {
+		if v.Name == "" {
+			return fmt.Errorf("variant name is required")
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("duplicate variant: %s", v.Name)
+		}
+		seen[v.Name] = true
+	}
+	return nil
+}
+
+// linkVariantIndex picks the variant used for link extraction. Variants that set
+// Accept-Encoding return bodies the crawler can't parse, so the first one that
+// leaves it alone is preferred.
+func linkVariantIndex(variants []RequestVariant) int {
+	for i, v := range variants {
+		hasEncoding := false
+		for name := range v.Headers {
+			if http.CanonicalHeaderKey(name) == "Accept-Encoding" {
+				hasEncoding = true
+				break
+			}
+		}
+		if !hasEncoding {
+			return i
+		}
+	}
+	return 0
+}
+
+// variantFailures describes the failed results, naming each by its variant and
+// edge, or returns "" when none failed
+func variantFailures(results []db.TaskResult) string {
+	var failures []string
+	for _, r := range results {
+		if r.Error == "" {
+			continue
+		}
+		name := r.Variant
+		if r.Edge != "" {
+			name += " at " + r.Edge
+		}
+		failures = append(failures, name+": "+r.Error)
+	}
+	return strings.Join(failures, "; ")
+}
+
+// serializeVariants stores variants as JSON, or NULL when the job has none
+func serializeVariants(variants []RequestVariant) interface{} {
+	if len(variants) == 0 {
+		return nil
+	}
+	return db.Serialize(variants)
+}
+
+// mergeEdges appends the edges that aren't already listed
+func mergeEdges(edges, more []string) []string {
+	seen := make(map[string]bool, len(edges))
+	for _, edge := range edges {
+		seen[edge] = true
+	}
+	for _, edge := range more {
+		if !seen[edge] {
+			seen[edge] = true
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+func copyHeaders(headers map[string]string) map[string]string {
+	copied := make(map[string]string, len(headers))
+	for k, v := range headers {
+		copied[k] = v
+	}
+	return copied
+}
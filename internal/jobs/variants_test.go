@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Harvey-AU/blue-banded-bee/internal/crawler"
+	"github.com/Harvey-AU/blue-banded-bee/internal/db"
+)
+
+func TestParseVariants(t *testing.T) {
+	variants, err := ParseVariants("desktop, mobile,br,lang-fr")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(variants) != 4 {
+		t.Fatalf("Expected 4 variants, got %d", len(variants))
+	}
+	if variants[2].Headers["Accept-Encoding"] != "br" {
+		t.Errorf("Expected br variant to set Accept-Encoding, got %v", variants[2].Headers)
+	}
+	if variants[3].Headers["Accept-Language"] != "fr" {
+		t.Errorf("Expected lang-fr variant to set Accept-Language, got %v", variants[3].Headers)
+	}
+
+	if _, err := ParseVariants("desktop,tablet"); err == nil {
+		t.Error("Expected error for unknown variant")
+	}
+	if _, err := ParseVariants("gzip,gzip"); err == nil {
+		t.Error("Expected error for duplicate variant")
+	}
+}
+
+func TestLinkVariantIndex(t *testing.T) {
+	variants := []RequestVariant{
+		{Name: "br", Headers: map[string]string{"accept-encoding": "br"}},
+		{Name: "mobile", Headers: map[string]string{"User-Agent": mobileUserAgent}},
+	}
+	if got := linkVariantIndex(variants); got != 1 {
+		t.Errorf("Expected link variant 1, got %d", got)
+	}
+
+	encodedOnly := variants[:1]
+	if got := linkVariantIndex(encodedOnly); got != 0 {
+		t.Errorf("Expected fallback to variant 0, got %d", got)
+	}
+}
+
+func TestVariantFailures(t *testing.T) {
+	results := []db.TaskResult{
+		{Variant: "mobile"},
+		{Variant: "br", Error: "HTTP 503: Service Unavailable"},
+		{Variant: "mobile", Edge: "104.16.1.1", Error: "connection refused"},
+	}
+	want := "br: HTTP 503: Service Unavailable; mobile at 104.16.1.1: connection refused"
+	if got := variantFailures(results); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got := variantFailures(results[:1]); got != "" {
+		t.Errorf("Expected no failures, got %q", got)
+	}
+}
+
+func TestWarmVariantsKeepsPrimaryWarning(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.Header.Get("User-Agent"), "iPhone") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// A soft 404 for the primary variant
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>Page Not Found</title></head><body>" + strings.Repeat("x", 200) + "</body></html>"))
+	}))
+	defer ts.Close()
+
+	variants, err := ParseVariants("desktop,mobile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wp := &WorkerPool{
+		crawler: crawler.New(nil),
+		dbQueue: db.NewDbQueue(sql.OpenDB(fakeConnector{query: func(string, []driver.Value) ([][]driver.Value, error) { return nil, nil }})),
+	}
+	task := &Task{ID: "task-1", JobID: "job-1", Variants: variants}
+
+	result, err := wp.warmVariants(context.Background(), task, ts.URL, crawler.WarmOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.WarningType != crawler.WarningSoft404 {
+		t.Errorf("Expected the primary soft 404 warning to be kept, got %q", result.WarningType)
+	}
+	if !strings.HasPrefix(task.VariantFailures, "mobile: ") {
+		t.Errorf("Expected the mobile failure to be recorded, got %q", task.VariantFailures)
+	}
+}
@@ -3,8 +3,10 @@ package jobs
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"math"
+	"net/http"
 	"net/url"
 	"runtime/debug"
 	"strings"
@@ -42,6 +44,12 @@ type WorkerPool struct {
 	cleanupInterval  time.Duration
 	notifyCh         chan struct{}
 	jobManager       *JobManager // Reference to JobManager for duplicate checking
+	robots           map[string]*jobRobots
+	robotsMutex      sync.Mutex
+	assets           map[string]map[string]bool // Asset URLs already enqueued, by job
+	assetsMutex      sync.Mutex
+	jars             map[string]http.CookieJar // Cookie jars of jobs with auth, by job
+	jarsMutex        sync.Mutex
 }
 
 // TaskBatch holds groups of tasks for batch processing
@@ -83,6 +91,9 @@ func NewWorkerPool(db *sql.DB, dbQueue *db.DbQueue, crawler *crawler.Crawler, nu
 		currentWorkers:  numWorkers,
 		jobs:            make(map[string]bool),
 		jobRequirements: make(map[string]int),
+		robots:          make(map[string]*jobRobots),
+		assets:          make(map[string]map[string]bool),
+		jars:            make(map[string]http.CookieJar),
 
 		stopCh:           make(chan struct{}),
 		notifyCh:         make(chan struct{}, 1), // Buffer of 1 to prevent blocking
@@ -175,6 +186,60 @@ func (wp *WorkerPool) AddJob(jobID string, options *JobOptions) {
 		Msg("Added job to worker pool")
 }
 
+// forgetJob drops the robots.txt rules, assets and cookies cached for a job
+func (wp *WorkerPool) forgetJob(jobID string) {
+	wp.forgetRobots(jobID)
+	wp.forgetAssets(jobID)
+	wp.forgetCookies(jobID)
+}
+
+// forgetFinishedJobs drops the caches of jobs that have completed, failed or been
+// cancelled. They're kept when a job leaves the pool, as a job briefly without
+// pending tasks can still have running tasks that find more.
+func (wp *WorkerPool) forgetFinishedJobs(ctx context.Context) error {
+	cached := make(map[string]bool)
+	wp.robotsMutex.Lock()
+	for jobID := range wp.robots {
+		cached[jobID] = true
+	}
+	wp.robotsMutex.Unlock()
+	wp.assetsMutex.Lock()
+	for jobID := range wp.assets {
+		cached[jobID] = true
+	}
+	wp.assetsMutex.Unlock()
+	wp.jarsMutex.Lock()
+	for jobID := range wp.jars {
+		cached[jobID] = true
+	}
+	wp.jarsMutex.Unlock()
+	if len(cached) == 0 {
+		return nil
+	}
+
+	jobIDs := make([]string, 0, len(cached))
+	for jobID := range cached {
+		jobIDs = append(jobIDs, jobID)
+	}
+	rows, err := wp.db.QueryContext(ctx, `
+		SELECT id FROM jobs WHERE id = ANY($1) AND status = ANY($2)
+	`, pq.Array(jobIDs), pq.Array([]string{string(JobStatusCompleted), string(JobStatusFailed), string(JobStatusCancelled)}))
+	if err != nil {
+		return fmt.Errorf("failed to find finished jobs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var jobID string
+		if err := rows.Scan(&jobID); err != nil {
+			return fmt.Errorf("failed to scan finished job: %w", err)
+		}
+		log.Debug().Str("job_id", jobID).Msg("Forgetting caches of finished job")
+		wp.forgetJob(jobID)
+	}
+	return rows.Err()
+}
+
 // RemoveJob removes a job from the worker pool
 func (wp *WorkerPool) RemoveJob(jobID string) {
 	wp.jobsMutex.Lock()
@@ -316,35 +381,51 @@ func (wp *WorkerPool) processNextTask(ctx context.Context) error {
 
 			// Convert db.Task to jobs.Task for processing
 			jobsTask := &Task{
-				ID:         task.ID,
-				JobID:      task.JobID,
-				PageID:     task.PageID,
-				Path:       task.Path,
-				Status:     TaskStatus(task.Status),
-				CreatedAt:  task.CreatedAt,
-				StartedAt:  task.StartedAt, 
-				RetryCount: task.RetryCount,
-				SourceType: task.SourceType,
-				SourceURL:  task.SourceURL,
+				ID:           task.ID,
+				JobID:        task.JobID,
+				PageID:       task.PageID,
+				Path:         task.Path,
+				Status:       TaskStatus(task.Status),
+				CreatedAt:    task.CreatedAt,
+				StartedAt:    task.StartedAt, 
+				RetryCount:   task.RetryCount,
+				SourceType:   task.SourceType,
+				SourceURL:    task.SourceURL,
+				Depth:        task.Depth,
+				ParentTaskID: task.ParentTaskID,
 			}
 			
 			// Need to fetch additional info from the database
-			var domainName string
-			var findLinks bool
-			err := wp.db.QueryRowContext(ctx, `
-				SELECT d.name, j.find_links 
-				FROM domains d
-				JOIN jobs j ON j.domain_id = d.id
-				WHERE j.id = $1
-			`, task.JobID).Scan(&domainName, &findLinks)
-			
-			if err != nil {
-				log.Error().Err(err).Str("job_id", task.JobID).Msg("Failed to get domain name and find_links setting")
-			} else {
-				jobsTask.DomainName = domainName
-				jobsTask.FindLinks = findLinks
+			// Without its job's settings the task would be warmed without auth, robots
+			// rules, depth limits or include/exclude rules, so it fails instead
+			if err := wp.loadJobConfig(ctx, jobsTask); err != nil {
+				log.Error().Err(err).Str("job_id", task.JobID).Msg("Failed to get domain name and job settings")
+				task.Status = string(TaskStatusFailed)
+				task.CompletedAt = time.Now()
+				task.Error = fmt.Sprintf("failed to load job settings: %v", err)
+				if updErr := wp.dbQueue.UpdateTaskStatus(ctx, task); updErr != nil {
+					log.Error().Err(updErr).Str("task_id", task.ID).Msg("Failed to mark task as failed")
+				}
+				if err := wp.dbQueue.UpdateJobProgress(ctx, task.JobID); err != nil {
+					log.Error().Err(err).Str("job_id", task.JobID).Msg("Failed to update job progress via helper")
+				}
+				return nil
 			}
-			
+
+			// Paths disallowed by robots.txt are skipped rather than warmed
+			if jobsTask.RespectRobots {
+				robots := wp.robotsFor(ctx, jobsTask)
+				if !robots.allowed(wp.crawler.Config().UserAgent, jobsTask) {
+					log.Info().Str("task_id", task.ID).Str("path", task.Path).Msg("Skipping task disallowed by robots.txt")
+					task.Status = string(TaskStatusSkipped)
+					task.SkipReason = robotsSkipReason
+					if err := wp.dbQueue.UpdateTaskStatus(ctx, task); err != nil {
+						log.Error().Err(err).Str("task_id", task.ID).Msg("Failed to mark task as skipped")
+					}
+					return nil
+				}
+			}
+
 			// Process the task
 			result, err := wp.processTask(ctx, jobsTask)
 			now := time.Now()
@@ -353,6 +434,11 @@ func (wp *WorkerPool) processNextTask(ctx context.Context) error {
 				task.Status = string(TaskStatusFailed)
 				task.CompletedAt = now
 				task.Error = err.Error()
+				if result != nil {
+					task.Attempts = result.RetryCount + 1
+					task.AttemptErrors = result.AttemptErrors
+					task.BytesTransferred = result.BytesTransferred
+				}
 				updErr := wp.dbQueue.UpdateTaskStatus(ctx, task)
 				if updErr != nil {
 					log.Error().Err(updErr).Str("task_id", task.ID).Msg("Failed to mark task as failed")
@@ -365,6 +451,26 @@ func (wp *WorkerPool) processNextTask(ctx context.Context) error {
 				task.ResponseTime = result.ResponseTime
 				task.CacheStatus = result.CacheStatus
 				task.ContentType = result.ContentType
+				task.CanonicalURL = result.Canonical
+				task.Attempts = result.RetryCount + 1
+				task.AttemptErrors = result.AttemptErrors
+				task.Warning = result.Warning
+				task.WarningType = result.WarningType
+				task.BytesTransferred = result.BytesTransferred
+				task.ContentLength = result.ContentLength
+				task.BodyTruncated = result.BodyTruncated
+				task.DNSLookupTime = result.DNSLookupTime
+				task.TCPConnectTime = result.TCPConnectTime
+				task.TLSHandshakeTime = result.TLSHandshakeTime
+				task.TTFB = result.TTFB
+				task.DownloadTime = result.DownloadTime
+				task.ConnReused = result.ConnReused
+				task.CacheStatusRaw = result.CacheStatusRaw
+				task.CDN = result.CDN
+				task.VariantFailures = jobsTask.VariantFailures
+				task.FirstCacheStatus = result.FirstCacheStatus
+				task.CacheAttempts = result.CacheAttempts
+				task.CacheVerified = result.CacheVerified
 				updErr := wp.dbQueue.UpdateTaskStatus(ctx, task)
 				if updErr != nil {
 					log.Error().Err(updErr).Str("task_id", task.ID).Msg("Failed to mark task as completed")
@@ -382,9 +488,79 @@ func (wp *WorkerPool) processNextTask(ctx context.Context) error {
 	return sql.ErrNoRows
 }
 
-// EnqueueURLs adds multiple URLs as tasks for a job
+// loadJobConfig populates the domain name and job settings needed to process a task
+func (wp *WorkerPool) loadJobConfig(ctx context.Context, task *Task) error {
+	var verifyDelayMs int
+	var variants, edges, assetHosts, urlPolicy, includePaths, excludePaths []byte
+	var encryptedAuth, baseURL, rulePrecedence sql.NullString
+	err := wp.db.QueryRowContext(ctx, `
+		SELECT d.name, j.find_links, j.verify_cache, j.verify_attempts, j.verify_delay_ms,
+			j.max_body_size, j.variants, j.edges, j.auth, j.respect_robots, j.base_url,
+			j.find_assets, j.asset_hosts, j.url_policy, j.respect_nofollow, j.enqueue_canonical, j.max_depth,
+			j.include_paths, j.exclude_paths, j.rule_precedence
+		FROM domains d
+		JOIN jobs j ON j.domain_id = d.id
+		WHERE j.id = $1
+	`, task.JobID).Scan(&task.DomainName, &task.FindLinks, &task.VerifyCache, &task.VerifyAttempts, &verifyDelayMs,
+		&task.MaxBodySize, &variants, &edges, &encryptedAuth, &task.RespectRobots, &baseURL,
+		&task.FindAssets, &assetHosts, &urlPolicy, &task.RespectNofollow, &task.EnqueueCanonical, &task.MaxDepth,
+		&includePaths, &excludePaths, &rulePrecedence)
+	if err != nil {
+		return err
+	}
+	task.BaseURL = baseURL.String
+
+	task.VerifyDelay = time.Duration(verifyDelayMs) * time.Millisecond
+	if len(variants) > 0 {
+		if err := json.Unmarshal(variants, &task.Variants); err != nil {
+			return fmt.Errorf("failed to unmarshal variants: %w", err)
+		}
+	}
+	if len(edges) > 0 {
+		if err := json.Unmarshal(edges, &task.Edges); err != nil {
+			return fmt.Errorf("failed to unmarshal edges: %w", err)
+		}
+	}
+	if len(assetHosts) > 0 {
+		if err := json.Unmarshal(assetHosts, &task.AssetHosts); err != nil {
+			return fmt.Errorf("failed to unmarshal asset hosts: %w", err)
+		}
+	}
+	if len(urlPolicy) > 0 {
+		if err := json.Unmarshal(urlPolicy, &task.URLPolicy); err != nil {
+			return fmt.Errorf("failed to unmarshal url policy: %w", err)
+		}
+	}
+	var include, exclude []string
+	if len(includePaths) > 0 {
+		if err := json.Unmarshal(includePaths, &include); err != nil {
+			return fmt.Errorf("failed to unmarshal include paths: %w", err)
+		}
+	}
+	if len(excludePaths) > 0 {
+		if err := json.Unmarshal(excludePaths, &exclude); err != nil {
+			return fmt.Errorf("failed to unmarshal exclude paths: %w", err)
+		}
+	}
+	if task.Rules, err = crawler.CompileURLRules(include, exclude, rulePrecedence.String); err != nil {
+		return fmt.Errorf("failed to compile include/exclude rules: %w", err)
+	}
+	if encryptedAuth.Valid && encryptedAuth.String != "" {
+		secret, err := db.DecryptSecret(encryptedAuth.String)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt auth: %w", err)
+		}
+		task.Auth = &crawler.RequestAuth{}
+		if err := json.Unmarshal(secret, task.Auth); err != nil {
+			return fmt.Errorf("failed to unmarshal auth: %w", err)
+		}
+	}
+	return nil
+}
+
+// EnqueueURLs adds multiple URLs as tasks for a job, found on the parent task
 // Legacy wrapper that delegates to dbQueue.EnqueueURLs
-func (wp *WorkerPool) EnqueueURLs(ctx context.Context, jobID string, pageIDs []int, urls []string, sourceType string, sourceURL string) error {
+func (wp *WorkerPool) EnqueueURLs(ctx context.Context, jobID string, pageIDs []int, urls []string, sourceType string, sourceURL string, parent *Task) error {
 	log.Debug().
 		Str("job_id", jobID).
 		Str("source_type", sourceType).
@@ -394,10 +570,13 @@ func (wp *WorkerPool) EnqueueURLs(ctx context.Context, jobID string, pageIDs []i
 	// Check if we have a job manager to use for duplicate checking
 	// If not, fall back to direct dbQueue usage
 	if wp.jobManager != nil {
-		return wp.jobManager.EnqueueJobURLs(ctx, jobID, pageIDs, urls, sourceType, sourceURL)
+		return wp.jobManager.EnqueueJobURLs(ctx, jobID, pageIDs, urls, sourceType, sourceURL, parent)
 	}
 	
-	return wp.dbQueue.EnqueueURLs(ctx, jobID, pageIDs, urls, sourceType, sourceURL)
+	if parent == nil {
+		return wp.dbQueue.EnqueueURLs(ctx, jobID, pageIDs, urls, sourceType, sourceURL, "", 0)
+	}
+	return wp.dbQueue.EnqueueURLs(ctx, jobID, pageIDs, urls, sourceType, sourceURL, parent.ID, parent.Depth+1)
 }
 
 // StartTaskMonitor starts a background process that monitors for pending tasks
@@ -420,6 +599,9 @@ func (wp *WorkerPool) StartTaskMonitor(ctx context.Context) {
 				if err := wp.checkForPendingTasks(ctx); err != nil {
 					log.Error().Err(err).Msg("Error checking for pending tasks")
 				}
+				if err := wp.forgetFinishedJobs(ctx); err != nil {
+					log.Error().Err(err).Msg("Error forgetting finished jobs")
+				}
 			}
 		}
 	}()
@@ -861,6 +1043,97 @@ func GetNextPendingTask(ctx context.Context, db *sql.DB, jobID string) (*Task, e
 	return &task, nil
 }
 
+// warmVariants warms the URL once per request variant of the job, both through normal
+// resolution and at each pinned edge, and stores a result for each combination. The
+// first variant's normally resolved result is returned for the task, with links taken
+// from the variant chosen for link extraction. Jobs without variants or edges make a
+// single request.
+func (wp *WorkerPool) warmVariants(ctx context.Context, task *Task, urlStr string, opts crawler.WarmOptions) (*crawler.CrawlResult, error) {
+	if len(task.Variants) == 0 && len(task.Edges) == 0 {
+		return wp.crawler.WarmURLWithOptions(ctx, urlStr, opts)
+	}
+
+	variants := task.Variants
+	if len(variants) == 0 {
+		variants = []RequestVariant{{Name: defaultVariantName}}
+	}
+	// An empty edge is the normally resolved request
+	edges := append([]string{""}, task.Edges...)
+
+	linkVariant := linkVariantIndex(variants)
+	var primary *crawler.CrawlResult
+	var primaryErr error
+	var links, assets []string
+	results := make([]db.TaskResult, 0, len(variants)*len(edges))
+
+	for i, variant := range variants {
+		for j, edge := range edges {
+			if ctx.Err() != nil {
+				break
+			}
+
+			variantOpts := opts
+			variantOpts.Headers = variant.Headers
+			variantOpts.Edge = edge
+			variantOpts.FindLinks = opts.FindLinks && i == linkVariant && j == 0
+			variantOpts.FindAssets = opts.FindAssets && i == linkVariant && j == 0
+
+			result, err := wp.crawler.WarmURLWithOptions(ctx, urlStr, variantOpts)
+			if i == 0 && j == 0 {
+				primary, primaryErr = result, err
+			}
+			if variantOpts.FindLinks && result != nil {
+				links = result.Links
+			}
+			if variantOpts.FindAssets && result != nil {
+				assets = result.Assets
+			}
+
+			taskResult := db.TaskResult{TaskID: task.ID, JobID: task.JobID, Variant: variant.Name, Edge: edge}
+			if result != nil {
+				taskResult.StatusCode = result.StatusCode
+				taskResult.ResponseTime = result.ResponseTime
+				taskResult.CacheStatus = result.CacheStatus
+				taskResult.FirstCacheStatus = result.FirstCacheStatus
+				taskResult.CacheVerified = result.CacheVerified
+				taskResult.BytesTransferred = result.BytesTransferred
+			}
+			if err != nil {
+				taskResult.Error = err.Error()
+			}
+			results = append(results, taskResult)
+
+			log.Debug().
+				Str("task_id", task.ID).
+				Str("variant", variant.Name).
+				Str("edge", edge).
+				Str("cache_status", taskResult.CacheStatus).
+				Str("error", taskResult.Error).
+				Msg("Warmed request variant")
+		}
+	}
+
+	if err := wp.dbQueue.SaveTaskResults(ctx, results); err != nil {
+		log.Error().Err(err).Str("task_id", task.ID).Msg("Failed to save variant results")
+	}
+
+	if primary == nil && primaryErr == nil {
+		primaryErr = ctx.Err()
+	}
+	if primary != nil && opts.FindLinks {
+		primary.Links = links
+	}
+	if primary != nil && opts.FindAssets {
+		primary.Assets = assets
+	}
+	// The task's status and warning come from the first variant at the default
+	// edge, so the other variants and edges failing is recorded separately
+	if primary != nil && primaryErr == nil && len(results) > 1 {
+		task.VariantFailures = variantFailures(results[1:])
+	}
+	return primary, primaryErr
+}
+
 // processTask processes an individual task
 func (wp *WorkerPool) processTask(ctx context.Context, task *Task) (*crawler.CrawlResult, error) {
 	// Construct a proper URL for processing
@@ -870,13 +1143,18 @@ func (wp *WorkerPool) processTask(ctx context.Context, task *Task) (*crawler.Cra
 	if strings.HasPrefix(task.Path, "http://") || strings.HasPrefix(task.Path, "https://") {
 		urlStr = task.Path
 	} else if task.DomainName != "" {
-		// If we have a domain name, construct the URL properly
+		// If we have a domain name, construct the URL properly, using the
+		// scheme and host discovery found the site served from
+		base := "https://" + task.DomainName
+		if task.BaseURL != "" {
+			base = task.BaseURL
+		}
 		if strings.HasPrefix(task.Path, "/") {
 			// The path starts with a slash, so it's a path relative to domain root
-			urlStr = fmt.Sprintf("https://%s%s", task.DomainName, task.Path)
+			urlStr = base + task.Path
 		} else {
 			// Add both slash and domain
-			urlStr = fmt.Sprintf("https://%s/%s", task.DomainName, task.Path)
+			urlStr = base + "/" + task.Path
 		}
 	} else {
 		// Fallback case - assume path is a full URL but missing protocol
@@ -885,7 +1163,22 @@ func (wp *WorkerPool) processTask(ctx context.Context, task *Task) (*crawler.Cra
 	
 	log.Info().Str("url", urlStr).Str("task_id", task.ID).Msg("Starting URL warm")
 
-	result, err := wp.crawler.WarmURL(ctx, urlStr, task.FindLinks)
+	// Pages at the job's maximum depth have no links worth following
+	findLinks := task.FindLinks && (task.MaxDepth == 0 || task.Depth < task.MaxDepth)
+
+	result, err := wp.warmVariants(ctx, task, urlStr, crawler.WarmOptions{
+		FindLinks:       findLinks,
+		FindAssets:      task.FindAssets,
+		RespectNofollow: task.RespectNofollow,
+		VerifyCache:     task.VerifyCache,
+		VerifyAttempts:  task.VerifyAttempts,
+		VerifyDelay:     task.VerifyDelay,
+		MaxBodySize:     task.MaxBodySize,
+		Auth:            task.Auth,
+		Jar:             wp.cookieJarFor(task),
+		EdgeHost:        siteHost(task),
+		Wait:            wp.crawlDelayWait(task),
+	})
 	if err != nil {
 		log.Error().Err(err).Str("task_id", task.ID).Msg("Crawler failed")
 		return result, fmt.Errorf("crawler error: %w", err)
@@ -895,6 +1188,14 @@ func (wp *WorkerPool) processTask(ctx context.Context, task *Task) (*crawler.Cra
 		Str("task_id", task.ID).
 		Int("links_found", len(result.Links)).
 		Str("content_type", result.ContentType).
+		Int("retries", result.RetryCount).
+		Str("cache_status", result.CacheStatus).
+		Str("cdn", result.CDN).
+		Bool("cache_verified", result.CacheVerified).
+		Str("warning_type", result.WarningType).
+		Int64("bytes", result.BytesTransferred).
+		Bool("truncated", result.BodyTruncated).
+		Int64("ttfb_ms", result.TTFB).
 		Msg("Crawler completed")
 
 	// Process discovered links if find_links is enabled
@@ -937,58 +1238,57 @@ func (wp *WorkerPool) processTask(ctx context.Context, task *Task) (*crawler.Cra
 
 		// Enqueue filtered links
 		if len(filtered) > 0 {
-			// Get domain ID for this job
-			var domainID int
-			err := wp.dbQueue.Execute(ctx, func(tx *sql.Tx) error {
-				return tx.QueryRowContext(ctx, `
-					SELECT domain_id FROM jobs WHERE id = $1
-				`, task.JobID).Scan(&domainID)
-			})
-			if err != nil {
-				log.Error().
-					Err(err).
-					Str("job_id", task.JobID).
-					Msg("Failed to get domain ID for discovered links")
-				return result, nil
-			}
-			
-			// Create page records for discovered links
-			pageIDs, paths, err := wp.createPageRecords(ctx, domainID, filtered)
-			if err != nil {
-				log.Error().
-					Err(err).
-					Str("task_id", task.ID).
-					Int("link_count", len(filtered)).
-					Msg("Failed to create page records for links")
-				return result, nil
-			}
-			
-			// Enqueue the filtered links with proper page IDs
-			if err := wp.EnqueueURLs(
-				ctx,
-				task.JobID,
-				pageIDs,
-				paths,
-				"link",     // source_type is "link" for discovered links
-				urlStr,     // source_url is the URL where these links were found
-			); err != nil {
-				log.Error().
-					Err(err).
-					Str("task_id", task.ID).
-					Int("link_count", len(filtered)).
-					Msg("Failed to enqueue discovered links")
-			} else {
-				log.Info().
-					Str("task_id", task.ID).
-					Int("link_count", len(filtered)).
-					Msg("Successfully enqueued discovered links")
-			}
+			wp.enqueueDiscovered(ctx, task, filtered, "link", urlStr)
+		}
+	}
+
+	// Enqueue the assets the page or stylesheet references that the job hasn't seen
+	if task.FindAssets && len(result.Assets) > 0 {
+		assets := filterAssets(result.Assets, task.DomainName, task.AssetHosts)
+		assets = wp.newAssets(task.JobID, task.URLPolicy.CanonicalizeURLs(assets))
+		log.Debug().
+			Str("task_id", task.ID).
+			Int("assets_found", len(result.Assets)).
+			Int("new_assets", len(assets)).
+			Msg("Asset filtering completed")
+		if len(assets) > 0 {
+			wp.enqueueDiscovered(ctx, task, assets, "asset", urlStr)
+		}
+	}
+
+	// Warm the canonical URL the page declares when it isn't the page itself
+	if task.EnqueueCanonical {
+		if canonical := canonicalTarget(task, urlStr, result.Canonical); canonical != "" {
+			log.Debug().
+				Str("task_id", task.ID).
+				Str("canonical_url", canonical).
+				Msg("Page declares a different canonical URL")
+			wp.enqueueDiscovered(ctx, task, []string{canonical}, "canonical", urlStr)
 		}
 	}
 
 	return result, nil
 }
 
+// canonicalTarget returns the canonical URL a page declares when it's on the
+// job's domain and, once both are canonicalised, isn't the page itself
+func canonicalTarget(task *Task, pageURL, canonical string) string {
+	if canonical == "" {
+		return ""
+	}
+	target, err := task.URLPolicy.Canonicalize(canonical)
+	if err != nil {
+		return ""
+	}
+	if u, err := url.Parse(target); err != nil || !isSameOrSubDomain(u.Hostname(), task.DomainName) {
+		return ""
+	}
+	if self, err := task.URLPolicy.Canonicalize(pageURL); err == nil && self == target {
+		return ""
+	}
+	return target
+}
+
 // Helper function to check if a hostname is the same domain or a subdomain of the target domain
 func isSameOrSubDomain(hostname, targetDomain string) bool {
 	// Direct match
@@ -1004,6 +1304,42 @@ func isSameOrSubDomain(hostname, targetDomain string) bool {
 	return false
 }
 
+// siteHost returns the host a task's job site is requested from, the host its
+// edges are pinned for
+func siteHost(task *Task) string {
+	if u, err := url.Parse(task.BaseURL); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	return task.DomainName
+}
+
+// pagePath returns the path stored for a page: the path and query for URLs on
+// the job's domain (with or without www.), or the full URL for other hosts so
+// subdomain, CDN and off-domain pages are requested from the right place.
+// Fragments are dropped as they're never sent to the server.
+func pagePath(domain, rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	if host != strings.TrimPrefix(strings.ToLower(domain), "www.") {
+		u.Fragment = ""
+		u.RawFragment = ""
+		return u.String()
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return path
+}
+
 // Helper function to check if a URL points to a document
 func isDocumentLink(path string) bool {
 	lower := strings.ToLower(path)
@@ -1016,101 +1352,59 @@ func isDocumentLink(path string) bool {
 		strings.HasSuffix(lower, ".pptx")
 }
 
-// createPageRecords creates page records for a list of URLs and returns their IDs and paths
-// This is used for handling discovered links in the worker
-func (wp *WorkerPool) createPageRecords(ctx context.Context, domainID int, urls []string) ([]int, []string, error) {
-	span := sentry.StartSpan(ctx, "worker.create_page_records")
-	defer span.Finish()
-
-	span.SetTag("domain_id", fmt.Sprintf("%d", domainID))
-	span.SetTag("url_count", fmt.Sprintf("%d", len(urls)))
-
+// enqueueDiscovered creates page records for URLs found while warming a task
+// that the job's rules keep, and enqueues them for the task's job
+func (wp *WorkerPool) enqueueDiscovered(ctx context.Context, task *Task, urls []string, sourceType, sourceURL string) {
+	// Apply the job's include/exclude rules to the URLs' canonical forms
+	urls = applyURLRules(ctx, wp.dbQueue, task.JobID, task.DomainName, task.Rules, task.URLPolicy.CanonicalizeURLs(urls))
 	if len(urls) == 0 {
-		return []int{}, []string{}, nil
+		return
 	}
 
-	pageIDs := make([]int, 0, len(urls))
-	paths := make([]string, 0, len(urls))
-
-	// Get domain name from the database
-	var domainName string
+	// Get domain ID for this job
+	var domainID int
 	err := wp.dbQueue.Execute(ctx, func(tx *sql.Tx) error {
 		return tx.QueryRowContext(ctx, `
-			SELECT name FROM domains WHERE id = $1
-		`, domainID).Scan(&domainName)
+			SELECT domain_id FROM jobs WHERE id = $1
+		`, task.JobID).Scan(&domainID)
 	})
 	if err != nil {
-		span.SetTag("error", "true")
-		span.SetData("error.message", err.Error())
-		return nil, nil, fmt.Errorf("failed to get domain name: %w", err)
-	}
-
-	// Extract paths from URLs
-	for _, url := range urls {
-		// Parse URL to extract the path
-		log.Debug().Str("original_url", url).Msg("Processing URL")
-
-		// Remove any protocol and domain to get just the path
-		path := url
-		// Strip common prefixes
-		path = strings.TrimPrefix(path, "http://")
-		path = strings.TrimPrefix(path, "https://")
-		path = strings.TrimPrefix(path, "www.")
-		
-		// Find the first slash after the domain name
-		domainEnd := strings.Index(path, "/")
-		if domainEnd != -1 {
-			// Extract just the path part
-			path = path[domainEnd:]
-		} else {
-			// If no path found, use root path
-			path = "/"
-		}
-
-		// Add paths to our result array
-		paths = append(paths, path)
-		log.Debug().Str("extracted_path", path).Msg("Extracted path from URL")
+		log.Error().
+			Err(err).
+			Str("job_id", task.JobID).
+			Str("source_type", sourceType).
+			Msg("Failed to get domain ID for discovered URLs")
+		return
 	}
 
-	// Insert pages into database in a transaction
-	err = wp.dbQueue.Execute(ctx, func(tx *sql.Tx) error {
-		// Prepare statement for bulk insertion
-		stmt, err := tx.PrepareContext(ctx, `
-			INSERT INTO pages (domain_id, path)
-			VALUES ($1, $2)
-			ON CONFLICT (domain_id, path) DO UPDATE SET path = EXCLUDED.path
-			RETURNING id
-		`)
-		if err != nil {
-			return fmt.Errorf("failed to prepare page insert statement: %w", err)
-		}
-		defer stmt.Close()
-
-		// Insert each page and collect the IDs
-		for _, path := range paths {
-			var pageID int
-			err := stmt.QueryRowContext(ctx, domainID, path).Scan(&pageID)
-			if err != nil {
-				return fmt.Errorf("failed to insert page record: %w", err)
-			}
-			pageIDs = append(pageIDs, pageID)
-		}
-
-		return nil
-	})
-
+	// Create page records for discovered URLs
+	pages, err := createPageRecords(ctx, wp.dbQueue, domainID, urls, task.URLPolicy)
 	if err != nil {
-		span.SetTag("error", "true")
-		span.SetData("error.message", err.Error())
-		return nil, nil, fmt.Errorf("failed to create page records: %w", err)
+		log.Error().
+			Err(err).
+			Str("task_id", task.ID).
+			Str("source_type", sourceType).
+			Int("url_count", len(urls)).
+			Msg("Failed to create page records for discovered URLs")
+		return
 	}
+	pageIDs, paths := pageIDsAndPaths(pages)
 
-	log.Debug().
-		Int("domain_id", domainID).
-		Int("page_count", len(pageIDs)).
-		Msg("Created page records")
-
-	return pageIDs, paths, nil
+	// Enqueue the discovered URLs with proper page IDs, recording where they were found
+	if err := wp.EnqueueURLs(ctx, task.JobID, pageIDs, paths, sourceType, sourceURL, task); err != nil {
+		log.Error().
+			Err(err).
+			Str("task_id", task.ID).
+			Str("source_type", sourceType).
+			Int("url_count", len(urls)).
+			Msg("Failed to enqueue discovered URLs")
+	} else {
+		log.Info().
+			Str("task_id", task.ID).
+			Str("source_type", sourceType).
+			Int("url_count", len(urls)).
+			Msg("Successfully enqueued discovered URLs")
+	}
 }
 
 // listenForNotifications sets up PostgreSQL LISTEN/NOTIFY
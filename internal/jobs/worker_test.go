@@ -0,0 +1,126 @@
+package jobs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Harvey-AU/blue-banded-bee/internal/crawler"
+)
+
+func TestPagePath(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/about", "/about"},
+		{"https://www.example.com/about?page=2", "/about?page=2"},
+		{"http://example.com", "/"},
+		{"https://example.com/about#team", "/about"},
+		{"https://blog.example.com/post", "https://blog.example.com/post"},
+		{"https://cdn.example.net/img/hero.jpg#x", "https://cdn.example.net/img/hero.jpg"},
+		{"https://example.com:8443/admin", "https://example.com:8443/admin"},
+	}
+	for _, tt := range tests {
+		if got := pagePath("example.com", tt.url); got != tt.want {
+			t.Errorf("pagePath(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"/about?page=2", "/about?page=2", true},
+		{"https://www.example.com/docs/a.pdf", "/docs/a.pdf", true},
+		{"https://blog.example.com/post", "", false},
+		{"https://example.com:8443/admin", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := robotsPath("example.com", tt.path)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("robotsPath(%q) = %q, %v, want %q, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCanonicalTarget(t *testing.T) {
+	task := &Task{DomainName: "example.com", URLPolicy: crawler.URLPolicy{TrailingSlash: crawler.TrailingSlashRemove}}
+	tests := []struct {
+		page      string
+		canonical string
+		want      string
+	}{
+		{"https://example.com/shoes?colour=red", "https://example.com/shoes", "https://example.com/shoes"},
+		{"https://example.com/shoes", "https://example.com/shoes/", ""},
+		{"https://example.com/shoes?utm_source=x", "https://EXAMPLE.com/shoes#top", ""},
+		{"https://example.com/a", "https://www.example.com/b", "https://www.example.com/b"},
+		{"https://example.com/a", "https://other.example.net/a", ""},
+		{"https://example.com/a", "", ""},
+	}
+	for _, tt := range tests {
+		if got := canonicalTarget(task, tt.page, tt.canonical); got != tt.want {
+			t.Errorf("canonicalTarget(%q, %q) = %q, want %q", tt.page, tt.canonical, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsForRetriesFailedFetch(t *testing.T) {
+	var down atomic.Bool
+	down.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			// Drop the connection so the fetch fails outright
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+	}))
+	defer server.Close()
+
+	wp := &WorkerPool{crawler: crawler.New(nil), robots: make(map[string]*jobRobots)}
+	task := &Task{JobID: "job-1", DomainName: strings.TrimPrefix(server.URL, "http://"), Path: "/private"}
+	userAgent := wp.crawler.Config().UserAgent
+
+	robots := wp.robotsFor(context.Background(), task)
+	if !robots.allowed(userAgent, task) {
+		t.Fatal("paths should be allowed while robots.txt can't be fetched")
+	}
+
+	// The failure isn't kept, so the rules are fetched once the retry is due
+	down.Store(false)
+	robots.retryAt = time.Time{}
+	if wp.robotsFor(context.Background(), task).allowed(userAgent, task) {
+		t.Error("robots.txt should be fetched again after a failed fetch")
+	}
+}
+
+func TestRobotsForServerErrorAndCancelledTask(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	wp := &WorkerPool{crawler: crawler.New(nil), robots: make(map[string]*jobRobots)}
+	task := &Task{JobID: "job-1", DomainName: strings.TrimPrefix(server.URL, "http://"), Path: "/page"}
+
+	// The fetch outlives the task that starts it
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	robots := wp.robotsFor(ctx, task)
+	if !robots.fetched {
+		t.Fatal("robots.txt fetch should not use the task's cancelled context")
+	}
+	if robots.allowed(wp.crawler.Config().UserAgent, task) {
+		t.Error("a server error from robots.txt should disallow every path")
+	}
+}